@@ -1,3 +1,6 @@
+-- Migration 1 of 'go/resources/products/migrations/mysql', with the 'archived'
+-- column of migration 2. Databases created from these files are recorded at
+-- version 2 by the products migrations.
 CREATE TABLE `products` (
   `id` INT(10),
   `legal_owner` INT(10) NOT NULL,
@@ -11,6 +14,7 @@ CREATE TABLE `products` (
   `repo_url` VARCHAR(255),
   `issues_url` VARCHAR(255),
   `ontology` ENUM ('TANGIBLE GOOD', 'DIGITAL GOOD', 'SOFTWARE SERVICE', 'CONSULTING SERVICE', 'PHYSICAL SERVICE'),
+  `archived` BOOLEAN NOT NULL DEFAULT 0,
 
   CONSTRAINT `products_key` PRIMARY KEY ( `id` ),
   CONSTRAINT `products_ref_entities` FOREIGN KEY ( `id` ) REFERENCES `entities` ( `id` ),
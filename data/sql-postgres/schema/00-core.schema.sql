@@ -0,0 +1,22 @@
+-- Minimal stand-ins for the catalyst-core-api 'entities' and 'users' tables,
+-- which are only provided for MySQL. 'last_updated' is in seconds since the
+-- epoch and is advanced by the products package on each change.
+CREATE TABLE entities (
+  id SERIAL,
+  pub_id VARCHAR(36) NOT NULL,
+  last_updated BIGINT NOT NULL DEFAULT CAST(EXTRACT(EPOCH FROM now()) AS BIGINT),
+
+  CONSTRAINT entities_key PRIMARY KEY ( id ),
+  CONSTRAINT entities_pub_id_unique UNIQUE ( pub_id )
+);
+
+CREATE TABLE users (
+  id INTEGER,
+  auth_id VARCHAR(255),
+  legal_id VARCHAR(64),
+  legal_id_type VARCHAR(16),
+  active BOOLEAN NOT NULL DEFAULT FALSE,
+
+  CONSTRAINT users_key PRIMARY KEY ( id ),
+  CONSTRAINT users_ref_entities FOREIGN KEY ( id ) REFERENCES entities ( id )
+);
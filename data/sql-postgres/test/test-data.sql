@@ -0,0 +1,15 @@
+-- PostgreSQL version of 'data/sql/test/test-data.sql'; the IDs are looked up
+-- by public ID in place of the MySQL variables.
+
+-- the legal owner
+INSERT INTO entities (pub_id) VALUES ('4C2B3954-8D7F-48BA-B720-3B0F15F91BA9');
+INSERT INTO users (id, auth_id, legal_id, legal_id_type, active)
+  VALUES ((SELECT id FROM entities WHERE pub_id='4C2B3954-8D7F-48BA-B720-3B0F15F91BA9'), 'xzy098', '55-5555555', 'EIN', FALSE);
+
+INSERT INTO entities (pub_id) VALUES ('D929BEE3-8034-40A9-B33E-E1A28507EE68');
+INSERT INTO products (id, legal_owner, display_name, summary, support_email, homepage, logo_url, repo_url, ontology)
+  VALUES ((SELECT id FROM entities WHERE pub_id='D929BEE3-8034-40A9-B33E-E1A28507EE68'), (SELECT id FROM entities WHERE pub_id='4C2B3954-8D7F-48BA-B720-3B0F15F91BA9'), 'Bauble', 'A thing for your wall.', 'bauble@foo.com', 'https://foo.com/proudcts/bauble', 'https://foo.com/assets/bauble_logo.svg', 'https://git.foo.com/bauble_repo', 'TANGIBLE GOOD');
+
+INSERT INTO entities (pub_id) VALUES ('016B5F34-D36A-4970-ADC8-4FADC01425D9');
+INSERT INTO products (id, legal_owner, display_name, summary, support_email, homepage, logo_url, repo_url, ontology)
+  VALUES ((SELECT id FROM entities WHERE pub_id='016B5F34-D36A-4970-ADC8-4FADC01425D9'), (SELECT id FROM entities WHERE pub_id='4C2B3954-8D7F-48BA-B720-3B0F15F91BA9'), 'Blog', 'Online articles.', 'blog@foo.com', 'https://foo.com/sass/blog', 'https://foo.com/assets/blog_logo.svg', 'https://git.foo.com/blog_repo', 'SOFTWARE SERVICE');
@@ -1,13 +1,32 @@
 module github.com/Liquid-Labs/catalyst-products-api
 
+// 'embed' requires Go 1.16, and modernc.org/sqlite Go 1.17.
+go 1.17
+
 require (
 	github.com/Liquid-Labs/catalyst-core-api v0.4.0
 	github.com/Liquid-Labs/catalyst-firewrap v1.0.0-prototype.0
 	github.com/Liquid-Labs/go-api v1.0.0-protottype.0
 	github.com/Liquid-Labs/go-nullable-mysql v1.0.2
 	github.com/Liquid-Labs/go-rest v1.0.0-prototype.2
+	github.com/go-sql-driver/mysql v1.4.1
 	github.com/gorilla/mux v1.7.0
+	github.com/lib/pq v1.10.9
 	github.com/stretchr/testify v1.3.0
+	modernc.org/sqlite v1.20.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/mattn/go-isatty v0.0.16 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20200410134404-eec4a21b6bb0 // indirect
+	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
+	modernc.org/libc v1.22.2 // indirect
+	modernc.org/mathutil v1.5.0 // indirect
+	modernc.org/memory v1.4.0 // indirect
 )
 
 replace github.com/Liquid-Labs/catalyst-core-api => /Users/zane/playground/catalyst-core-api
@@ -1,6 +1,10 @@
 package main
 
 import (
+  "log"
+  "os"
+  "time"
+
   "github.com/Liquid-Labs/catalyst-core-api/go/restserv"
   // core resources
   "github.com/Liquid-Labs/catalyst-core-api/go/resources/entities"
@@ -11,10 +15,50 @@ import (
 )
 
 func main() {
-  sqldb.RegisterSetup(entities.SetupDB)
-  sqldb.RegisterSetup(users.SetupDB)
-  sqldb.RegisterSetup(products.SetupDB)
-  sqldb.InitDB()
+  if timeout := os.Getenv(`PRODUCTS_QUERY_TIMEOUT`); timeout != `` {
+    if d, err := time.ParseDuration(timeout); err != nil {
+      log.Fatalf("Invalid PRODUCTS_QUERY_TIMEOUT '%s': %v", timeout, err)
+    } else {
+      products.QueryTimeout = d
+    }
+  }
+  switch migrate := os.Getenv(`PRODUCTS_MIGRATE`); migrate {
+  case ``, `apply`:
+    products.MigrateOnSetup = true
+  case `verify`:
+    products.MigrateOnSetup = false
+  default:
+    log.Fatalf("Invalid PRODUCTS_MIGRATE '%s'; expected 'apply' or 'verify'.", migrate)
+  }
+  switch driver := os.Getenv(`PRODUCTS_DB_DRIVER`); driver {
+  case ``, `mysql`:
+    sqldb.RegisterSetup(entities.SetupDB)
+    sqldb.RegisterSetup(users.SetupDB)
+    sqldb.RegisterSetup(products.SetupDB)
+    sqldb.InitDB()
+  case `sqlite`:
+    path := os.Getenv(`PRODUCTS_SQLITE_PATH`)
+    if path == `` {
+      path = `products.db`
+    }
+    if db, err := products.OpenSQLite(path); db == nil {
+      log.Fatal(err)
+    } else if err != nil {
+      log.Printf("Products store unavailable: %v", err)
+    }
+  case `postgres`:
+    dsn := os.Getenv(`PRODUCTS_POSTGRES_DSN`)
+    if dsn == `` {
+      log.Fatal("PRODUCTS_POSTGRES_DSN must be set for the 'postgres' driver.")
+    }
+    if db, err := products.OpenPostgres(dsn); db == nil {
+      log.Fatal(err)
+    } else if err != nil {
+      log.Printf("Products store unavailable: %v", err)
+    }
+  default:
+    log.Fatalf("Unknown PRODUCTS_DB_DRIVER '%s'; expected 'mysql', 'postgres', or 'sqlite'.", driver)
+  }
   restserv.RegisterResource(products.InitAPI)
   restserv.Init()
 }
@@ -1,12 +1,21 @@
 package products
 
 import (
+  "context"
+  "encoding/json"
   "fmt"
+  "io/ioutil"
+  "log"
   "net/http"
+  "strconv"
+  "strings"
+  "time"
 
   "github.com/gorilla/mux"
 
   "github.com/Liquid-Labs/catalyst-core-api/go/handlers"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
 )
 
 func pingHandler(w http.ResponseWriter, r *http.Request) {
@@ -15,41 +24,729 @@ func pingHandler(w http.ResponseWriter, r *http.Request) {
 
 func createHandler(w http.ResponseWriter, r *http.Request) {
   var product *Product = &Product{}
-  if _, restErr := handlers.CheckAndExtract(w, r, product, `Product`); restErr != nil {
-    return // response handled by CheckAndExtract
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else if err := rest.ExtractJson(w, r, product, `Product`); err != nil {
+    return // response handled by ExtractJson
   } else {
-    handlers.DoCreate(w, r, CreateProduct, product, `Product`)
+    if newProduct, restErr := productStore.Create(product, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      setETag(w, newProduct)
+      rest.StandardResponse(w, newProduct, `Product created.`, nil)
+    }
+  }
+}
+
+// handleError reports a ValidationError as JSON, keyed by field name, so that
+// clients may associate each problem with the corresponding input. Other
+// errors are handled by rest.HandleError.
+func handleError(w http.ResponseWriter, restErr rest.RestError) {
+  if validationErr, ok := restErr.(ValidationError); ok {
+    statusResponse(w, validationErr.Code(), validationErr.Fields, validationErr.Error())
+  } else {
+    rest.HandleError(w, restErr)
+  }
+}
+
+const defaultItemsPerPage = 50
+const maxItemsPerPage = 500
+
+// extractSearchParams builds the search parameters from the 'search', 'sort',
+// 'page' (1-based), and 'itemsPerPage' query parameters. The 'cursor'
+// parameter is handled separately; see listHandler.
+func extractSearchParams(r *http.Request) (*rest.SearchParams, rest.RestError) {
+  query := r.URL.Query()
+
+  pageIndex, itemsPerPage := 1, defaultItemsPerPage
+  if val := query.Get(`page`); val != `` {
+    var err error
+    if pageIndex, err = strconv.Atoi(val); err != nil || pageIndex < 1 {
+      return nil, rest.BadRequestError(fmt.Sprintf(`Invalid page '%s'; must be a positive integer.`, val), err)
+    }
+  }
+  if val := query.Get(`itemsPerPage`); val != `` {
+    var err error
+    if itemsPerPage, err = strconv.Atoi(val); err != nil || itemsPerPage < 1 || itemsPerPage > maxItemsPerPage {
+      return nil, rest.BadRequestError(fmt.Sprintf(`Invalid itemsPerPage '%s'; must be between 1 and %d.`, val, maxItemsPerPage), err)
+    }
+  }
+
+  terms := make([]string, 0)
+  if term := strings.TrimSpace(query.Get(`search`)); term != `` {
+    terms = append(terms, term)
+  }
+
+  return &rest.SearchParams{
+    Terms: terms,
+    Sort: query.Get(`sort`),
+    PageInfo: &rest.PageInfo{PageIndex: pageIndex, ItemsPerPage: itemsPerPage},
+  }, nil
+}
+
+// presenceFilterParams maps the presence filter query parameters to the
+// filtered fields.
+var presenceFilterParams = map[string]string{`hasRepoURL`: `repoURL`, `hasIssuesURL`: `issuesURL`, `hasLogoURL`: `logoURL`}
+
+// extractFilters builds the filters from the 'ontology' (repeated or comma
+// separated), 'legalOwner' (public ID), 'hasRepoURL', 'hasIssuesURL',
+// 'hasLogoURL' ('true' or 'false'), 'updatedSince' (RFC 3339 or seconds since
+// the epoch), and 'supportEmailDomain' query parameters, along with the
+// 'searchMode'. The values are checked when the filters are applied.
+func extractFilters(r *http.Request) (*ProductFilters, rest.RestError) {
+  query := r.URL.Query()
+  filters := &ProductFilters{
+    LegalOwnerPubID: query.Get(`legalOwner`),
+    Present: make(map[string]bool),
+    SupportEmailDomain: query.Get(`supportEmailDomain`),
+    SearchMode: query.Get(`searchMode`),
+  }
+
+  for _, val := range query[`ontology`] {
+    for _, ontology := range strings.Split(val, `,`) {
+      if ontology = strings.TrimSpace(ontology); ontology != `` {
+        filters.Ontologies = append(filters.Ontologies, ontology)
+      }
+    }
+  }
+  for param, field := range presenceFilterParams {
+    if val := query.Get(param); val != `` {
+      present, err := strconv.ParseBool(val)
+      if err != nil {
+        return nil, rest.BadRequestError(fmt.Sprintf(`Invalid %s '%s'; must be 'true' or 'false'.`, param, val), err)
+      }
+      filters.Present[field] = present
+    }
+  }
+  if val := query.Get(`updatedSince`); val != `` {
+    if since, err := strconv.ParseInt(val, 10, 64); err == nil {
+      filters.UpdatedSince = since
+    } else if since, err := time.Parse(time.RFC3339, val); err == nil {
+      filters.UpdatedSince = since.Unix()
+    } else {
+      return nil, rest.BadRequestError(fmt.Sprintf(`Invalid updatedSince '%s'; must be an RFC 3339 time or seconds since the epoch.`, val), err)
+    }
+  }
+
+  return filters, nil
+}
+
+// includeArchived checks for the 'includeArchived=true' query parameter.
+func includeArchived(r *http.Request) bool {
+  return r.URL.Query().Get(`includeArchived`) == `true`
+}
+
+// setETag sets the 'ETag' header from the Product 'LastUpdated' value. This is
+// the value expected in the 'If-Match' header when updating the Product.
+func setETag(w http.ResponseWriter, p *Product) {
+  if p.LastUpdated.Valid {
+    w.Header().Set(`ETag`, fmt.Sprintf(`"%d"`, p.LastUpdated.Int64))
+  }
+}
+
+// prepareUpdate reconciles the target public ID and, if given, the 'If-Match'
+// header with the submitted Product data. The 'If-Match' ETag takes precedence
+// over any 'lastUpdated' in the body.
+func prepareUpdate(r *http.Request, p *Product, pubID string) rest.RestError {
+  if p.PubId.Valid && p.PubId.String != pubID {
+    return rest.BadRequestError(fmt.Sprintf(`Product pubId '%s' does not match target '%s'.`, p.PubId.String, pubID), nil)
+  }
+  p.PubId = nulls.NewString(pubID)
+
+  if ifMatch := strings.TrimSpace(r.Header.Get(`If-Match`)); ifMatch != `` {
+    etag := strings.Trim(strings.TrimPrefix(ifMatch, `W/`), `"`)
+    lastUpdated, err := strconv.ParseInt(etag, 10, 64)
+    if err != nil {
+      return rest.BadRequestError(fmt.Sprintf(`Could not parse 'If-Match' header '%s'.`, ifMatch), err)
+    }
+    p.LastUpdated = nulls.NewInt64(lastUpdated)
+  }
+
+  return nil
+}
+
+func listHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    searchParams, restErr := extractSearchParams(r)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+    filters, restErr := extractFilters(r)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+    resp := &listResponse{Message: `Products retrieved.`}
+
+    // With 'facets=true', the facet counts accompany the Products.
+    if r.URL.Query().Get(`facets`) == `true` {
+      if resp.Facets, restErr = productStore.Facets(searchParams, includeArchived(r), filters, ctx); restErr != nil {
+        handleError(w, restErr)
+        return
+      }
+    }
+
+    // The presence of 'cursor', even if empty, selects cursor paging.
+    firstPage := searchParams.PageInfo.PageIndex == 1
+    if cursor, ok := r.URL.Query()[`cursor`]; ok {
+      if r.URL.Query().Get(`page`) != `` {
+        handleError(w, rest.BadRequestError(`Specify either 'page' or 'cursor', not both.`, nil))
+        return
+      }
+      page, restErr := productStore.ListPage(searchParams, includeArchived(r), filters, cursor[0], ctx)
+      if restErr != nil {
+        handleError(w, restErr)
+        return
+      }
+      resp.Data, resp.Cursors = page.Products, &listCursors{page.Next, page.Prev}
+      firstPage = cursor[0] == ``
+    } else {
+      if resp.Data, restErr = productStore.List(searchParams, includeArchived(r), filters, ctx); restErr != nil {
+        handleError(w, restErr)
+        return
+      }
+      resp.SearchParams = searchParams
+    }
+
+    // A search without hits may be misspelled.
+    if len(resp.Data) == 0 && firstPage && len(searchParams.Terms) > 0 {
+      if resp.DidYouMean, restErr = productStore.Suggest(strings.Join(searchParams.Terms, ` `), didYouMeanLimit, includeArchived(r), filters, ctx); restErr != nil {
+        handleError(w, restErr)
+        return
+      }
+    }
+
+    resp.write(w)
+  }
+}
+
+// didYouMeanLimit is the number of suggestions offered for a search without
+// hits.
+const didYouMeanLimit = 3
+
+type listCursors struct {
+  Next string `json:"next,omitempty"`
+  Prev string `json:"prev,omitempty"`
+}
+
+// listResponse is the rest.StandardResponse format for listings, extended with
+// the optional cursors (in place of the search parameters when paging by
+// cursor), facets, and suggestions for a search without hits.
+type listResponse struct {
+  Data         []*Product           `json:"data"`
+  Message      string               `json:"message"`
+  SearchParams *rest.SearchParams   `json:"searchParams,omitempty"`
+  Cursors      *listCursors         `json:"cursors,omitempty"`
+  Facets       *ProductFacets       `json:"facets,omitempty"`
+  DidYouMean   []*ProductSuggestion `json:"didYouMean,omitempty"`
+}
+
+func (resp *listResponse) write(w http.ResponseWriter) {
+  respBody, err := json.Marshal(resp)
+  if err != nil {
+    rest.HandleError(w, rest.ServerError("Could not format response.", err))
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  w.Write(respBody)
+}
+
+const defaultSuggestLimit = 10
+const maxSuggestLimit = 50
+
+// suggestHandler offers the Products with display names resembling the 'q'
+// query parameter, for autocompletion. Up to 'limit' suggestions are given,
+// best first, honoring the 'includeArchived' and filter parameters as for
+// listing.
+func suggestHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    query := r.URL.Query()
+    q := strings.TrimSpace(query.Get(`q`))
+    if q == `` {
+      handleError(w, rest.BadRequestError(`Missing query 'q'.`, nil))
+      return
+    }
+    limit := defaultSuggestLimit
+    if val := query.Get(`limit`); val != `` {
+      var err error
+      if limit, err = strconv.Atoi(val); err != nil || limit < 1 || limit > maxSuggestLimit {
+        handleError(w, rest.BadRequestError(fmt.Sprintf(`Invalid limit '%s'; must be between 1 and %d.`, val, maxSuggestLimit), err))
+        return
+      }
+    }
+    filters, restErr := extractFilters(r)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+
+    if suggestions, restErr := productStore.Suggest(q, limit, includeArchived(r), filters, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, suggestions, `Suggestions retrieved.`, nil)
+    }
   }
 }
 
 func detailHandler(w http.ResponseWriter, r *http.Request) {
-  if _, restErr := handlers.BasicAuthCheck(w, r); restErr != nil {
-    return // response handled by BasicAuthCheck
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
   } else {
     vars := mux.Vars(r)
     pubID := vars["pubId"]
 
-    handlers.DoGetDetail(w, r, GetProduct, pubID, `Product`)
+    if product, restErr := productStore.Get(pubID, includeArchived(r), ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      setETag(w, product)
+      rest.StandardResponse(w, product, `Product retrieved.`, nil)
+    }
   }
 }
 
 func updateHandler(w http.ResponseWriter, r *http.Request) {
   var newData *Product = &Product{}
-  if _, restErr := handlers.CheckAndExtract(w, r, newData, `Product`); restErr != nil {
-    return // response handled by CheckAndExtract
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else if err := rest.ExtractJson(w, r, newData, `Product`); err != nil {
+    return // response handled by ExtractJson
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+
+    if restErr := prepareUpdate(r, newData, pubID); restErr != nil {
+      handleError(w, restErr)
+    } else if product, restErr := productStore.Update(newData, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      setETag(w, product)
+      rest.StandardResponse(w, product, `Product updated.`, nil)
+    }
+  }
+}
+
+const maxPatchBytes = 1 << 20
+
+func patchHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+
+    var applyPatch func(*Product, []byte) (*Product, []string, rest.RestError)
+    var versioned func([]byte) bool
+    switch mediaType := strings.TrimSpace(strings.Split(r.Header.Get(`Content-Type`), `;`)[0]); mediaType {
+    case MergePatchContentType, `application/json`:
+      applyPatch, versioned = ApplyMergePatch, mergePatchVersioned
+    case JSONPatchContentType:
+      applyPatch, versioned = ApplyJSONPatch, jsonPatchVersioned
+    default:
+      handleError(w, unsupportedMediaTypeError(fmt.Sprintf(`Unsupported patch type '%s'; use '%s' or '%s'.`, mediaType, MergePatchContentType, JSONPatchContentType), nil))
+      return
+    }
+
+    patch, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+    if err != nil {
+      handleError(w, rest.BadRequestError(`Could not read patch.`, err))
+      return
+    }
+    defer r.Body.Close()
+
+    // The patch is applied to the Product as locked for the update. As the
+    // patched Product carries the current 'lastUpdated', the patch itself must
+    // identify the version it applies to, per the 'If-Match' header or the
+    // 'lastUpdated' in the patch document.
+    ifMatch := strings.TrimSpace(r.Header.Get(`If-Match`)) != ``
+    patchFunc := func(current *Product) (*Product, []string, rest.RestError) {
+      patched, fields, restErr := applyPatch(current, patch)
+      if restErr != nil {
+        return nil, nil, restErr
+      }
+      if !ifMatch && !versioned(patch) {
+        return nil, nil, preconditionRequiredError(fmt.Sprintf(`Patch of product '%s' must specify 'If-Match' or 'lastUpdated'.`, pubID), nil)
+      }
+      if restErr := prepareUpdate(r, patched, pubID); restErr != nil {
+        return nil, nil, restErr
+      }
+      return patched, fields, nil
+    }
+
+    if product, restErr := PatchProductWith(pubID, patchFunc, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      setETag(w, product)
+      rest.StandardResponse(w, product, `Product updated.`, nil)
+    }
+  }
+}
+
+func deleteHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+
+    if product, restErr := productStore.Delete(pubID, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, product, `Product archived.`, nil)
+    }
+  }
+}
+
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+
+    if product, restErr := RestoreProduct(pubID, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, product, `Product restored.`, nil)
+    }
+  }
+}
+
+func revisionsHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+
+    if revisions, restErr := GetProductRevisions(pubID, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, revisions, `Product revisions retrieved.`, nil)
+    }
+  }
+}
+
+func revisionHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
   } else {
     vars := mux.Vars(r)
     pubID := vars["pubId"]
+    revision, err := strconv.ParseInt(vars["revision"], 10, 64)
+    if err != nil {
+      handleError(w, rest.BadRequestError(fmt.Sprintf(`Invalid revision '%s'.`, vars["revision"]), err))
+      return
+    }
 
-    handlers.DoUpdate(w, r, UpdateProduct, newData, pubID, `Product`)
+    if productRevision, restErr := GetProductRevision(pubID, revision, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, productRevision, `Product revision retrieved.`, nil)
+    }
   }
 }
 
+// revisionsDiff is the JSON rendering of a revision diff.
+type revisionsDiff struct {
+  From    int64       `json:"from"`
+  To      int64       `json:"to"`
+  Changes []FieldDiff `json:"changes"`
+}
+
+// diffHandler reports the changes between the 'from' and 'to' revisions. The
+// diff is rendered as unified text if 'format=text' or the request accepts
+// 'text/plain' (but not JSON), and as JSON otherwise.
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+    query := r.URL.Query()
+
+    var revisions [2]int64
+    for i, param := range []string{`from`, `to`} {
+      val := query.Get(param)
+      revision, err := strconv.ParseInt(val, 10, 64)
+      if err != nil || revision < 1 {
+        handleError(w, rest.BadRequestError(fmt.Sprintf(`Invalid or missing '%s' revision '%s'.`, param, val), err))
+        return
+      }
+      revisions[i] = revision
+    }
+
+    diffs, restErr := DiffProductRevisions(pubID, revisions[0], revisions[1], ctx)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+
+    accept := r.Header.Get(`Accept`)
+    if query.Get(`format`) == `text` || (strings.Contains(accept, `text/plain`) && !strings.Contains(accept, `application/json`)) {
+      w.Header().Set(`Content-Type`, `text/plain; charset=utf-8`)
+      fmt.Fprint(w, FormatDiffText(fmt.Sprintf(`revision %d`, revisions[0]), fmt.Sprintf(`revision %d`, revisions[1]), diffs))
+    } else {
+      rest.StandardResponse(w, revisionsDiff{revisions[0], revisions[1], diffs}, `Product revisions compared.`, nil)
+    }
+  }
+}
+
+// revertRequest is the optional body of a revert request.
+type revertRequest struct {
+  LastUpdated nulls.Int64  `json:"lastUpdated"`
+  ChangeDesc  nulls.String `json:"changeDesc"`
+}
+
+func revertHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    vars := mux.Vars(r)
+    pubID := vars["pubId"]
+    revision, err := strconv.ParseInt(vars["revision"], 10, 64)
+    if err != nil {
+      handleError(w, rest.BadRequestError(fmt.Sprintf(`Invalid revision '%s'.`, vars["revision"]), err))
+      return
+    }
+
+    var revertReq revertRequest
+    if r.ContentLength != 0 {
+      if err := rest.ExtractJson(w, r, &revertReq, `revert request`); err != nil {
+        return // response handled by ExtractJson
+      }
+    }
+    // 'prepareUpdate' applies any 'If-Match' header.
+    target := &Product{}
+    target.LastUpdated = revertReq.LastUpdated
+    if restErr := prepareUpdate(r, target, pubID); restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+
+    if product, restErr := RevertProduct(pubID, revision, target.LastUpdated, revertReq.ChangeDesc, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      setETag(w, product)
+      rest.StandardResponse(w, product, fmt.Sprintf(`Product reverted to revision %d.`, revision), nil)
+    }
+  }
+}
+
+// statusResponse writes a response in the same format as
+// rest.StandardResponse, but with the given status code.
+func statusResponse(w http.ResponseWriter, code int, d interface{}, message string) {
+  respBody, err := json.Marshal(struct {
+    Data    interface{} `json:"data"`
+    Message string      `json:"message"`
+  }{d, message})
+  if err != nil {
+    rest.HandleError(w, rest.ServerError("Could not format response.", err))
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(code)
+  w.Write(respBody)
+}
+
+func bulkHandler(w http.ResponseWriter, r *http.Request) {
+  var products []*Product
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else if err := rest.ExtractJson(w, r, &products, `Products`); err != nil {
+    return // response handled by ExtractJson
+  } else {
+    atomic := r.URL.Query().Get(`atomic`) == `true`
+    results, restErr := BulkSaveProducts(products, atomic, ctx)
+    if restErr != nil && results == nil {
+      handleError(w, restErr)
+    } else if restErr != nil {
+      statusResponse(w, restErr.Code(), results, fmt.Sprintf(`Bulk save rolled back: %s`, restErr.Error()))
+    } else {
+      rest.StandardResponse(w, results, `Bulk save processed.`, nil)
+    }
+  }
+}
+
+const maxImportBytes = 10 << 20
+
+// importHandler imports a 'text/csv' body. With 'dryRun=true', the import is
+// checked but nothing is saved.
+func importHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    if mediaType := strings.TrimSpace(strings.Split(r.Header.Get(`Content-Type`), `;`)[0]); mediaType != `text/csv` {
+      handleError(w, unsupportedMediaTypeError(fmt.Sprintf(`Unsupported import type '%s'; use 'text/csv'.`, mediaType), nil))
+      return
+    }
+    defer r.Body.Close()
+
+    dryRun := r.URL.Query().Get(`dryRun`) == `true`
+    if report, restErr := ImportProductsCSV(http.MaxBytesReader(w, r.Body, maxImportBytes), dryRun, ctx); restErr != nil {
+      handleError(w, restErr)
+    } else {
+      rest.StandardResponse(w, report, fmt.Sprintf(`Processed %d rows; %d failed.`, report.Rows, report.Failed), nil)
+    }
+  }
+}
+
+const exportFlushInterval = 100
+const exportCursorTrailer = `Products-Cursor`
+
+// exportHandler streams the catalog as CSV or newline delimited JSON, per the
+// 'format' parameter, honoring the 'search', 'sort', 'includeArchived', and
+// filter parameters as for listing. The export starts after the 'cursor', if given;
+// see ExportProducts.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+  if ctx, restErr := authCheck(w, r); restErr != nil {
+    return // response handled by authCheck
+  } else {
+    searchParams, restErr := extractSearchParams(r)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+    filters, restErr := extractFilters(r)
+    if restErr != nil {
+      handleError(w, restErr)
+      return
+    }
+
+    var write func(*Product) error
+    var flush func() error
+    switch format := r.URL.Query().Get(`format`); format {
+    case `csv`, ``:
+      csvWriter := NewProductCSVWriter(w)
+      w.Header().Set(`Content-Type`, `text/csv; charset=utf-8`)
+      w.Header().Set(`Content-Disposition`, `attachment; filename="products.csv"`)
+      write, flush = csvWriter.Write, csvWriter.Flush
+    case `ndjson`:
+      encoder := json.NewEncoder(w)
+      w.Header().Set(`Content-Type`, `application/x-ndjson`)
+      w.Header().Set(`Content-Disposition`, `attachment; filename="products.ndjson"`)
+      write = func(p *Product) error { return encoder.Encode(p) }
+      flush = func() error { return nil }
+    default:
+      handleError(w, rest.BadRequestError(fmt.Sprintf(`Unknown export format '%s'; use 'csv' or 'ndjson'.`, format), nil))
+      return
+    }
+
+    // The cursor following the last Product written is sent as a trailer, so
+    // an interrupted export may be resumed.
+    w.Header().Set(`Trailer`, exportCursorTrailer)
+    count, cursor := 0, ``
+    emit := func(p *Product) error {
+      if err := write(p); err != nil {
+        return err
+      }
+      count += 1
+      cursor = CursorAfter(p, searchParams.Sort)
+      if count % exportFlushInterval == 0 {
+        if err := flush(); err != nil {
+          return err
+        }
+        if flusher, ok := w.(http.Flusher); ok {
+          flusher.Flush()
+        }
+      }
+      return nil
+    }
+
+    restErr = ExportProducts(searchParams, includeArchived(r), filters, r.URL.Query().Get(`cursor`), emit, ctx)
+    if restErr != nil && count == 0 {
+      w.Header().Del(`Trailer`)
+      handleError(w, restErr)
+      return
+    } else if restErr != nil { // too late to change the response status
+      log.Printf("ERROR: export failed after %d products: %+v", count, restErr.Cause())
+    } else if err := flush(); err != nil {
+      log.Printf("ERROR: export failed after %d products: %+v", count, err)
+    }
+    w.Header().Set(exportCursorTrailer, cursor)
+  }
+}
+
+// authCheck verifies the request is authenticated, as
+// 'handlers.BasicAuthCheck', and returns the request Context identifying the
+// authenticated user as the actor for any changes; see ContextWithActor. If
+// the check fails, the response has been handled.
+var authCheck = func(w http.ResponseWriter, r *http.Request) (context.Context, rest.RestError) {
+  authClient, restErr := handlers.BasicAuthCheck(w, r)
+  if restErr != nil {
+    return nil, restErr
+  }
+  return ContextWithActor(r.Context(), authClient.GetToken().UID), nil
+}
+
 const uuidRE = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}`
 
+// productStore backs the basic create, retrieve, update, delete, and list
+// handlers. The remaining handlers use the SQL functions directly and are
+// registered only with a SQLStore.
+var productStore ProductStore = SQLStore{}
+
+// InitAPI registers the products API backed by the database set up with
+// SetupDB or OpenSQLite.
 func InitAPI(r *mux.Router) {
-  r.HandleFunc("/products/", pingHandler).Methods("PING")
-  r.HandleFunc("/products/", createHandler).Methods("POST")
-  r.HandleFunc("/products/{pubId:" + uuidRE + "}/", detailHandler).Methods("GET")
-  r.HandleFunc("/products/{pubId:" + uuidRE + "}/", updateHandler).Methods("PUT")
+  InitAPIWithStore(SQLStore{})(r)
+}
+
+// InitAPIWithStore creates an API initializer, suitable for
+// 'restserv.RegisterResource', backed by the given ProductStore. The bulk,
+// import/export, patch, restore, and revision endpoints require a SQL database
+// and are only registered when 'store' is a SQLStore. With a SQLStore, every
+// endpoint responds with a 503 while the store is unavailable.
+func InitAPIWithStore(store ProductStore) func(*mux.Router) {
+  return func(r *mux.Router) {
+    productStore = store
+    _, isSQL := store.(SQLStore)
+    handler := func(h http.HandlerFunc) http.HandlerFunc {
+      if isSQL {
+        return requireSQLStore(h)
+      }
+      return h
+    }
+
+    r.HandleFunc("/products/", handler(pingHandler)).Methods("PING")
+    r.HandleFunc("/products/", handler(createHandler)).Methods("POST")
+    r.HandleFunc("/products/", handler(listHandler)).Methods("GET")
+    r.HandleFunc("/products/_suggest", handler(suggestHandler)).Methods("GET")
+    r.HandleFunc("/products/{pubId:" + uuidRE + "}/", handler(detailHandler)).Methods("GET")
+    r.HandleFunc("/products/{pubId:" + uuidRE + "}/", handler(updateHandler)).Methods("PUT")
+    r.HandleFunc("/products/{pubId:" + uuidRE + "}/", handler(deleteHandler)).Methods("DELETE")
+
+    if isSQL {
+      initSQLAPI(r)
+    }
+  }
+}
+
+func initSQLAPI(r *mux.Router) {
+  r.HandleFunc("/products/_bulk", requireSQLStore(bulkHandler)).Methods("POST")
+  r.HandleFunc("/products/_import", requireSQLStore(importHandler)).Methods("POST")
+  r.HandleFunc("/products/_export", requireSQLStore(exportHandler)).Methods("GET")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/", requireSQLStore(patchHandler)).Methods("PATCH")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/restore", requireSQLStore(restoreHandler)).Methods("POST")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/revisions/", requireSQLStore(revisionsHandler)).Methods("GET")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/revisions/diff", requireSQLStore(diffHandler)).Methods("GET")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/revisions/{revision:[0-9]+}", requireSQLStore(revisionHandler)).Methods("GET")
+  r.HandleFunc("/products/{pubId:" + uuidRE + "}/revisions/{revision:[0-9]+}/revert", requireSQLStore(revertHandler)).Methods("POST")
+}
+
+// requireSQLStore responds with a 503, giving the reason, while the SQL store
+// is unavailable; see StoreUnavailable.
+func requireSQLStore(h http.HandlerFunc) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    if err := StoreUnavailable(); err != nil {
+      w.Header().Set(`Retry-After`, strconv.Itoa(int(SetupRetryInterval / time.Second)))
+      handleError(w, unavailableError(fmt.Sprintf(`Products store unavailable; %v`, err), err))
+      return
+    }
+    h(w, r)
+  }
 }
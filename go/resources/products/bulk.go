@@ -0,0 +1,124 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+  "net/http"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+const MaxBulkItems = 500
+
+// BulkError reports the failure of a single bulk item. Validation failures
+// are additionally reported by field.
+type BulkError struct {
+  Code    int         `json:"code"`
+  Message string      `json:"message"`
+  Fields  FieldErrors `json:"fields,omitempty"`
+}
+
+// BulkResult reports the outcome of a single bulk item. Exactly one of
+// 'Product' or 'Error' is set.
+type BulkResult struct {
+  Index   int        `json:"index"`
+  Product *Product   `json:"product,omitempty"`
+  Error   *BulkError `json:"error,omitempty"`
+}
+
+func newBulkError(restErr rest.RestError) *BulkError {
+  bulkErr := &BulkError{Code: restErr.Code(), Message: restErr.Error()}
+  if validationErr, ok := restErr.(ValidationError); ok {
+    bulkErr.Fields = validationErr.Fields
+  }
+  return bulkErr
+}
+
+func nullBulkItemError(index int) rest.RestError {
+  return rest.BadRequestError(fmt.Sprintf(`Bulk item %d is null.`, index), nil)
+}
+
+// BulkSaveProducts creates each Product without a public ID and updates each
+// Product with one. The results are reported per item, in order.
+//
+// If 'atomic' is true, all items are saved within a single transaction and the
+// first failure rolls back the entire batch. In that case, the returned
+// rest.RestError is that of the failed item, which is reported along with the
+// remaining items as not applied. Otherwise, each item is saved in its own
+// transaction and failures are reported only in the item results. Null items
+// fail with a 400; in the atomic case, before anything is saved.
+func BulkSaveProducts(products []*Product, atomic bool, ctx context.Context) ([]*BulkResult, rest.RestError) {
+  if len(products) > MaxBulkItems {
+    return nil, rest.BadRequestError(fmt.Sprintf(`Bulk requests are limited to %d items; got %d.`, MaxBulkItems, len(products)), nil)
+  }
+
+  if atomic {
+    // A null item (as from a 'null' JSON array element) would fail the batch
+    // anyway, so reject it before starting.
+    for i, p := range products {
+      if p == nil {
+        return nil, nullBulkItemError(i)
+      }
+    }
+    return bulkSaveAtomic(products, ctx)
+  }
+
+  results := make([]*BulkResult, len(products))
+  for i, p := range products {
+    var saved *Product
+    var restErr rest.RestError
+    if p == nil {
+      restErr = nullBulkItemError(i)
+    } else if p.PubId.Valid {
+      saved, restErr = UpdateProduct(p, ctx)
+    } else {
+      saved, restErr = CreateProduct(p, ctx)
+    }
+    results[i] = &BulkResult{Index: i}
+    if restErr != nil {
+      results[i].Error = newBulkError(restErr)
+    } else {
+      results[i].Product = saved
+    }
+  }
+
+  return results, nil
+}
+
+func bulkSaveAtomic(products []*Product, ctx context.Context) ([]*BulkResult, rest.RestError) {
+  var results []*BulkResult
+  var itemFailed bool
+  restErr := WithTxn(ctx, func(txn *sql.Tx) rest.RestError {
+    // WithTxn may retry, so each attempt starts with fresh results.
+    results, itemFailed = make([]*BulkResult, len(products)), false
+    for i, p := range products {
+      var saved *Product
+      var restErr rest.RestError
+      if p.PubId.Valid {
+        saved, restErr = UpdateProductInTxn(p, ctx, txn)
+      } else {
+        saved, restErr = CreateProductInTxn(p, ctx, txn)
+      }
+      results[i] = &BulkResult{Index: i, Product: saved}
+
+      if restErr != nil {
+        for j := range results {
+          if j == i {
+            results[j] = &BulkResult{Index: j, Error: newBulkError(restErr)}
+          } else {
+            results[j] = &BulkResult{Index: j, Error: &BulkError{Code: http.StatusFailedDependency, Message: fmt.Sprintf(`Not applied; item %d failed.`, i)}}
+          }
+        }
+        itemFailed = true
+        return restErr
+      }
+    }
+    return nil
+  })
+
+  if restErr != nil && !itemFailed { // failed to begin or commit
+    return nil, restErr
+  }
+  return results, restErr
+}
@@ -0,0 +1,289 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "strconv"
+  "strings"
+
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// The CSV columns, other than the writable productFields, which may be
+// imported. A row with a 'pubId' updates the identified Product, in which case
+// 'lastUpdated' is required.
+var csvExtraColumns = []string{pubIdJSONName, lastUpdatedJSONName, changeDescJSONName}
+
+// csvFieldError indicates a CSV value could not be converted.
+type csvFieldError struct {
+  field   string
+  message string
+}
+
+func (e *csvFieldError) Error() string {
+  return e.message
+}
+
+// ProductCSVReader reads Products from CSV data. The first record must be a
+// header naming the columns by their Product JSON names; e.g., 'displayName',
+// 'supportEmail', 'ontology'. Empty values are read as null.
+type ProductCSVReader struct {
+  reader  *csv.Reader
+  columns []string
+  row     int
+}
+
+// NewProductCSVReader creates a ProductCSVReader, reading and verifying the
+// header. Unknown or duplicate columns result in a rest.BadRequestError.
+func NewProductCSVReader(r io.Reader) (*ProductCSVReader, rest.RestError) {
+  reader := csv.NewReader(r)
+  reader.TrimLeadingSpace = true
+  header, err := reader.Read()
+  if err == io.EOF {
+    return nil, rest.BadRequestError(`CSV is empty; expected a header.`, nil)
+  } else if err != nil {
+    return nil, rest.BadRequestError(`Could not read CSV header.`, err)
+  }
+
+  seen := make(map[string]bool)
+  for i, column := range header {
+    column = strings.TrimSpace(column)
+    if i == 0 {
+      column = strings.TrimPrefix(column, "\ufeff") // spreadsheet BOM
+    }
+    if !isCSVColumn(column) {
+      return nil, rest.BadRequestError(fmt.Sprintf(`Unknown CSV column '%s'.`, column), nil)
+    }
+    if seen[column] {
+      return nil, rest.BadRequestError(fmt.Sprintf(`Duplicate CSV column '%s'.`, column), nil)
+    }
+    seen[column] = true
+    header[i] = column
+  }
+
+  return &ProductCSVReader{reader, header, 1}, nil
+}
+
+func isCSVColumn(column string) bool {
+  if _, ok := findProductField(column); ok {
+    return true
+  }
+  for _, extra := range csvExtraColumns {
+    if column == extra {
+      return true
+    }
+  }
+  return false
+}
+
+// Read reads the next Product. The row number, counting the header as row 1,
+// is returned even when the record is in error so the error can be reported.
+// A row error is not fatal and reading may continue. At the end of the data,
+// Read returns io.EOF.
+func (r *ProductCSVReader) Read() (int, *Product, error) {
+  record, err := r.reader.Read()
+  r.row += 1
+  if err != nil {
+    return r.row, nil, err
+  }
+
+  p := &Product{}
+  for i, column := range r.columns {
+    val := strings.TrimSpace(record[i])
+    if val == `` {
+      continue // leave null
+    }
+    switch column {
+    case pubIdJSONName:
+      p.PubId = nulls.NewString(val)
+    case lastUpdatedJSONName:
+      lastUpdated, err := strconv.ParseInt(val, 10, 64)
+      if err != nil {
+        return r.row, nil, &csvFieldError{column, fmt.Sprintf(`Invalid 'lastUpdated' value '%s'.`, val)}
+      }
+      p.LastUpdated = nulls.NewInt64(lastUpdated)
+    case changeDescJSONName:
+      p.ChangeDesc = nulls.NewString(val)
+    default:
+      field, _ := findProductField(column)
+      *field.value(p) = nulls.NewString(val)
+    }
+  }
+
+  return r.row, p, nil
+}
+
+// ProductCSVWriter writes Products as CSV data readable by ProductCSVReader.
+// The 'pubId' and 'lastUpdated' columns are included, so an exported Product
+// imports as an update to the original.
+type ProductCSVWriter struct {
+  writer *csv.Writer
+  wroteHeader bool
+}
+
+// NewProductCSVWriter creates a ProductCSVWriter. The header is written with
+// the first Product.
+func NewProductCSVWriter(w io.Writer) *ProductCSVWriter {
+  return &ProductCSVWriter{csv.NewWriter(w), false}
+}
+
+// Write writes a single Product record, preceded by the header if this is the
+// first record. As with csv.Writer, records are buffered; call Flush to ensure
+// they are written.
+func (w *ProductCSVWriter) Write(p *Product) error {
+  if !w.wroteHeader {
+    header := []string{pubIdJSONName, lastUpdatedJSONName}
+    for _, field := range productFields {
+      header = append(header, field.jsonName)
+    }
+    if err := w.writer.Write(header); err != nil {
+      return err
+    }
+    w.wroteHeader = true
+  }
+
+  record := make([]string, 0, len(productFields) + 2)
+  record = append(record, p.PubId.String, ``)
+  if p.LastUpdated.Valid {
+    record[1] = strconv.FormatInt(p.LastUpdated.Int64, 10)
+  }
+  for _, field := range productFields {
+    record = append(record, field.value(p).String)
+  }
+
+  return w.writer.Write(record)
+}
+
+// Flush writes any buffered records and reports any write error.
+func (w *ProductCSVWriter) Flush() error {
+  w.writer.Flush()
+  return w.writer.Error()
+}
+
+// ImportRowError reports the failure of a single CSV row.
+type ImportRowError struct {
+  Row     int    `json:"row"`
+  Field   string `json:"field,omitempty"`
+  Code    int    `json:"code"`
+  Message string `json:"message"`
+}
+
+// ImportReport summarizes a CSV import.
+type ImportReport struct {
+  DryRun  bool             `json:"dryRun"`
+  Rows    int              `json:"rows"`
+  Created int              `json:"created"`
+  Updated int              `json:"updated"`
+  Failed  int              `json:"failed"`
+  Errors  []ImportRowError `json:"errors"`
+}
+
+// ImportProductsCSV creates or updates a Product for each CSV row; see
+// ProductCSVReader for the format. Each row is saved in its own transaction
+// and a failed row is reported without affecting other rows. With 'dryRun',
+// each row is processed and then rolled back, so the report reflects what
+// would happen without changing anything. A rest.RestError is returned only if
+// the CSV as a whole cannot be processed.
+func ImportProductsCSV(r io.Reader, dryRun bool, ctx context.Context) (*ImportReport, rest.RestError) {
+  reader, restErr := NewProductCSVReader(r)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  report := &ImportReport{DryRun: dryRun, Errors: make([]ImportRowError, 0)}
+  knownOwners := make(map[string]bool)
+  for {
+    row, p, err := reader.Read()
+    if err == io.EOF {
+      break
+    }
+    report.Rows += 1
+    if err != nil {
+      rowErr := ImportRowError{Row: row, Code: http.StatusUnprocessableEntity, Message: err.Error()}
+      if fieldErr, ok := err.(*csvFieldError); ok {
+        rowErr.Field = fieldErr.field
+      } else if _, ok := err.(*csv.ParseError); !ok {
+        return nil, rest.BadRequestError(fmt.Sprintf(`Could not read CSV row %d.`, row), err)
+      }
+      report.addError(rowErr)
+      continue
+    }
+
+    if p.LegalOwnerPubID.Valid && !knownOwners[p.LegalOwnerPubID.String] {
+      var ownerID int64
+      lookupCtx, cancel := operationContext(ctx)
+      err := productsDB().QueryRowContext(lookupCtx, sqlDialect().Rebind(legalOwnerIDStatement), p.LegalOwnerPubID.String).Scan(&ownerID)
+      cancel()
+      if err != nil && err != sql.ErrNoRows {
+        return nil, ClassifySQLError(`Could not verify legal owner.`, err)
+      } else if err != nil {
+        report.addError(ImportRowError{row, `legalOwnerPubID`, http.StatusUnprocessableEntity, fmt.Sprintf(`Legal owner '%s' not found.`, p.LegalOwnerPubID.String)})
+        continue
+      }
+      knownOwners[p.LegalOwnerPubID.String] = true
+    }
+
+    if validationErr := p.Validate(); validationErr != nil {
+      for _, field := range productFields { // report in column order
+        for _, problem := range validationErr.Fields[field.jsonName] {
+          report.Errors = append(report.Errors, ImportRowError{row, field.jsonName, http.StatusUnprocessableEntity, problem})
+        }
+      }
+      report.Failed += 1
+    } else if restErr := importProduct(p, dryRun, ctx); restErr != nil {
+      report.addError(ImportRowError{Row: row, Code: restErr.Code(), Message: restErr.Error()})
+    } else if p.PubId.Valid {
+      report.Updated += 1
+    } else {
+      report.Created += 1
+    }
+  }
+
+  return report, nil
+}
+
+func (report *ImportReport) addError(rowErr ImportRowError) {
+  report.Failed += 1
+  report.Errors = append(report.Errors, rowErr)
+}
+
+// dryRunError signals WithTxn to roll back a dry run import. It is a type of
+// its own, so that no genuine error may be mistaken for it.
+type dryRunError struct{}
+
+func (dryRunError) Error() string {
+  return `Dry run.`
+}
+
+func (dryRunError) Code() int {
+  return http.StatusOK
+}
+
+func (dryRunError) Cause() error {
+  return nil
+}
+
+func importProduct(p *Product, dryRun bool, ctx context.Context) rest.RestError {
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    if p.PubId.Valid {
+      _, restErr = UpdateProductInTxn(p, ctx, txn)
+    } else {
+      _, restErr = CreateProductInTxn(p, ctx, txn)
+    }
+    if restErr == nil && dryRun {
+      restErr = dryRunError{}
+    }
+    return
+  })
+
+  if errors.Is(restErr, dryRunError{}) {
+    return nil
+  }
+  return restErr
+}
@@ -0,0 +1,76 @@
+package products_test
+
+import (
+  "encoding/csv"
+  "io"
+  "strings"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+const productsCSV = "\ufeffdisplayName,summary,supportEmail,ontology,lastUpdated,pubId\n" +
+  "Gizmo,A gizmo.,gizmo@test.com,TANGIBLE GOOD,,\n" +
+  "\"Gizmo, Deluxe\",,gizmo@test.com,,12,D929BEE3-8034-40A9-B33E-E1A28507EE68\n" +
+  "Gizmo,too,few\n" +
+  "Gizmo,A gizmo.,gizmo@test.com,TANGIBLE GOOD,yesterday,\n"
+
+func TestProductCSVReader(t *testing.T) {
+  reader, restErr := NewProductCSVReader(strings.NewReader(productsCSV))
+  require.NoError(t, restErr, `Unexpected error reading header.`)
+
+  row, p, err := reader.Read()
+  require.NoError(t, err, `Unexpected error reading row.`)
+  assert.Equal(t, 2, row, `Unexpected row number.`)
+  assert.Equal(t, `Gizmo`, p.DisplayName.String, `Unexpected display name.`)
+  assert.Equal(t, `TANGIBLE GOOD`, p.Ontology.String, `Unexpected ontology.`)
+  assert.False(t, p.PubId.Valid, `Unexpected pubId.`)
+  assert.False(t, p.Homepage.Valid, `Unexpected homepage.`)
+
+  row, p, err = reader.Read()
+  require.NoError(t, err, `Unexpected error reading row.`)
+  assert.Equal(t, 3, row, `Unexpected row number.`)
+  assert.Equal(t, `Gizmo, Deluxe`, p.DisplayName.String, `Unexpected display name.`)
+  assert.False(t, p.Summary.Valid, `Empty summary not null.`)
+  assert.Equal(t, int64(12), p.LastUpdated.Int64, `Unexpected lastUpdated.`)
+  assert.Equal(t, `D929BEE3-8034-40A9-B33E-E1A28507EE68`, p.PubId.String, `Unexpected pubId.`)
+
+  row, _, err = reader.Read()
+  assert.Equal(t, 4, row, `Unexpected row number.`)
+  assert.IsType(t, &csv.ParseError{}, err, `Expected parse error for short row.`)
+
+  row, _, err = reader.Read()
+  assert.Equal(t, 5, row, `Unexpected row number.`)
+  assert.Error(t, err, `Expected error for bad 'lastUpdated'.`)
+
+  _, _, err = reader.Read()
+  assert.Equal(t, io.EOF, err, `Expected EOF.`)
+}
+
+func TestProductCSVReaderBadHeader(t *testing.T) {
+  _, restErr := NewProductCSVReader(strings.NewReader("displayName,color\n"))
+  assert.Error(t, restErr, `Expected error for unknown column.`)
+  _, restErr = NewProductCSVReader(strings.NewReader("summary,summary\n"))
+  assert.Error(t, restErr, `Expected error for duplicate column.`)
+  _, restErr = NewProductCSVReader(strings.NewReader(``))
+  assert.Error(t, restErr, `Expected error for empty CSV.`)
+}
+
+func TestProductCSVRoundTrip(t *testing.T) {
+  var data strings.Builder
+  writer := NewProductCSVWriter(&data)
+  require.NoError(t, writer.Write(widgetProduct), `Unexpected error writing Product.`)
+  require.NoError(t, writer.Flush(), `Unexpected error flushing CSV.`)
+
+  reader, restErr := NewProductCSVReader(strings.NewReader(data.String()))
+  require.NoError(t, restErr, `Unexpected error reading header.`)
+  _, p, err := reader.Read()
+  require.NoError(t, err, `Unexpected error reading row.`)
+  expected := widgetProduct.Clone()
+  expected.Archived = nulls.NewNullBool() // not exported
+  expected.ChangeDesc = nulls.NewNullString() // not exported
+  assert.Empty(t, DiffProducts(expected, p), `Exported product does not match original.`)
+}
@@ -0,0 +1,289 @@
+package products
+
+import (
+  "context"
+  "encoding/base64"
+  "encoding/json"
+  "fmt"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// sortKey describes the keyset on which a listing is paged by cursor. Ties on
+// 'column' are broken by 'p.id', in the same direction.
+type sortKey struct {
+  column string
+  desc   bool
+  // value extracts the 'column' value from a Product.
+  value  func(*Product) string
+}
+
+func displayNameKey(p *Product) string { return p.DisplayName.String }
+
+// productsSortKeys gives the keyset for each of the ProductsSorts which
+// support cursor paging.
+var productsSortKeys = map[string]*sortKey{
+  ``: {`p.display_name`, false, displayNameKey},
+  `name-asc`: {`p.display_name`, false, displayNameKey},
+  `name-desc`: {`p.display_name`, true, displayNameKey},
+}
+
+// productCursor is the position encoded in a cursor token. The listing
+// continues after ('Backward' false) or before the Product with sort key 'Key'
+// and internal ID 'Id'.
+type productCursor struct {
+  Sort     string `json:"s"`
+  Key      string `json:"k"`
+  Id       int64  `json:"i"`
+  Backward bool   `json:"b,omitempty"`
+}
+
+func (c *productCursor) encode() string {
+  data, _ := json.Marshal(c) // can't fail
+  return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor retrieves the sort key for 'sort' and the position encoded in
+// 'token'. The position is nil when 'token' is empty, indicating the first
+// page.
+func decodeCursor(token string, sort string) (*sortKey, *productCursor, rest.RestError) {
+  key, ok := productsSortKeys[sort]
+  if !ok {
+    if _, ok := ProductsSorts[sort]; ok {
+      return nil, nil, rest.BadRequestError(fmt.Sprintf(`Sort '%s' does not support cursor paging.`, sort), nil)
+    }
+    return nil, nil, rest.BadRequestError(fmt.Sprintf(`Unknown sort '%s'.`, sort), nil)
+  }
+  if token == `` {
+    return key, nil, nil
+  }
+
+  var position productCursor
+  data, err := base64.RawURLEncoding.DecodeString(token)
+  if err == nil {
+    err = json.Unmarshal(data, &position)
+  }
+  if err != nil {
+    return nil, nil, rest.BadRequestError(`Invalid cursor.`, err)
+  }
+  if position.Sort != sort {
+    return nil, nil, rest.BadRequestError(fmt.Sprintf(`Cursor is for sort '%s', not '%s'.`, position.Sort, sort), nil)
+  }
+  return key, &position, nil
+}
+
+// CursorAfter generates the cursor token positioned after the Product in the
+// given sort, or the empty string if the sort does not support cursor paging.
+func CursorAfter(p *Product, sort string) string {
+  key, ok := productsSortKeys[sort]
+  if !ok {
+    return ``
+  }
+  return (&productCursor{Sort: sort, Key: key.value(p), Id: p.Id.Int64}).encode()
+}
+
+// bits generates the WHERE clause restricting the listing to those Products
+// past the position, if any, and the ORDER BY expression walking away from
+// the position. Backward positions are walked in reverse sort order.
+func (k *sortKey) bits(position *productCursor, params []interface{}) (string, string, []interface{}) {
+  descending := k.desc
+  if position != nil && position.Backward {
+    descending = !descending
+  }
+  comparison, direction := `>`, `ASC`
+  if descending {
+    comparison, direction = `<`, `DESC`
+  }
+
+  var whereBit string
+  if position != nil {
+    whereBit = fmt.Sprintf(`AND (%[1]s %[2]s ? OR (%[1]s = ? AND p.id %[2]s ?)) `, k.column, comparison)
+    params = append(params, position.Key, position.Key, position.Id)
+  }
+  return whereBit, fmt.Sprintf(`%s %s, p.id %s `, k.column, direction, direction), params
+}
+
+// after implements the 'bits' WHERE clause for the MemoryStore, reporting
+// whether the Product is past the forward position.
+func (k *sortKey) after(p *Product, position *productCursor) bool {
+  value, id := k.value(p), p.Id.Int64
+  if k.desc {
+    return value < position.Key || (value == position.Key && id < position.Id)
+  }
+  return value > position.Key || (value == position.Key && id > position.Id)
+}
+
+// ProductPage is a page of Products retrieved by cursor. 'Next' and 'Prev' are
+// the cursors for the following and preceding pages, and are empty when there
+// are no more Products in that direction.
+type ProductPage struct {
+  Products []*Product
+  Next     string
+  Prev     string
+}
+
+// newProductPage builds the page from up to 'limit' + 1 Products retrieved
+// walking away from the position; the extra Product indicates there are more
+// in that direction.
+func newProductPage(products []*Product, limit int, sort string, position *productCursor) *ProductPage {
+  backward := position != nil && position.Backward
+  more := len(products) > limit
+  if more {
+    products = products[:limit]
+  }
+  if backward {
+    for i, j := 0, len(products) - 1; i < j; i, j = i + 1, j - 1 {
+      products[i], products[j] = products[j], products[i]
+    }
+  }
+
+  page := &ProductPage{Products: products}
+  if len(products) == 0 {
+    // Products may have been removed since the cursor was issued; allow the
+    // client to turn back.
+    if position != nil {
+      turned := *position
+      turned.Backward = !backward
+      if backward {
+        page.Next = turned.encode()
+      } else {
+        page.Prev = turned.encode()
+      }
+    }
+    return page
+  }
+
+  key := productsSortKeys[sort]
+  first, last := products[0], products[len(products) - 1]
+  if more || backward {
+    page.Next = (&productCursor{Sort: sort, Key: key.value(last), Id: last.Id.Int64}).encode()
+  }
+  if (more && backward) || (position != nil && !backward) {
+    page.Prev = (&productCursor{Sort: sort, Key: key.value(first), Id: first.Id.Int64, Backward: true}).encode()
+  }
+  return page
+}
+
+// pageLimit is the number of Products per page, defaulting to
+// defaultItemsPerPage.
+func pageLimit(searchParams *rest.SearchParams) int {
+  if searchParams.PageInfo == nil || searchParams.PageInfo.ItemsPerPage < 1 {
+    return defaultItemsPerPage
+  }
+  return searchParams.PageInfo.ItemsPerPage
+}
+
+// ListProductsPage retrieves the page of Products following, or preceding, the
+// position encoded in 'cursor'; the first page if 'cursor' is empty. The
+// search terms, filters, and sort are as for ListProducts, but the sort must
+// support cursor paging and must match the cursor. Only 'ItemsPerPage' of
+// 'searchParams.PageInfo', if set, is used. Unlike offset paging, the pages
+// remain consistent as Products are added and removed, and later pages are as
+// cheap to retrieve as the first. The total counts are not calculated.
+func ListProductsPage(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, cursor string, ctx context.Context) (*ProductPage, rest.RestError) {
+  key, position, restErr := decodeCursor(cursor, searchParams.Sort)
+  if restErr != nil {
+    return nil, restErr
+  }
+  bits, restErr := buildSearchBits(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  keyBit, order, params := key.bits(position, bits.params)
+  limit := pageLimit(searchParams)
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
+
+  query := CommonProductGet + bits.where + keyBit + `ORDER BY ` + order + `LIMIT ?`
+  rows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(query), append(params, limit + 1)...)
+  if err != nil {
+    return nil, ClassifySQLError("Error retrieving products.", err)
+  }
+  defer rows.Close()
+
+  results, err := BuildProductResults(rows)
+  if err != nil {
+    return nil, rest.ServerError("Problem getting data for products.", err)
+  } else if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Error retrieving products.", err)
+  }
+  products := results.([]*Product)
+  for _, product := range products {
+    bits.formatOut(product)
+  }
+
+  return newProductPage(products, limit, searchParams.Sort, position), nil
+}
+
+// ProductIterator walks every Product in a listing, a page at a time, using
+// cursors. Each page is retrieved separately, so the walk holds no database
+// resources between pages. Typical use:
+//
+//   products := NewProductIterator(store, searchParams, false, nil, ctx)
+//   for products.Next() {
+//     for _, p := range products.Page() {
+//       ...
+//     }
+//   }
+//   if restErr := products.Err(); restErr != nil {
+//     ...
+//   }
+type ProductIterator struct {
+  store           ProductStore
+  searchParams    *rest.SearchParams
+  includeArchived bool
+  filters         *ProductFilters
+  ctx             context.Context
+  page            *ProductPage
+  cursor          string
+  done            bool
+  err             rest.RestError
+}
+
+// NewProductIterator creates an iterator over the Products listed by the store
+// for the search parameters and filters; see ListProductsPage. The iterator starts with
+// the first page.
+func NewProductIterator(store ProductStore, searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) *ProductIterator {
+  return &ProductIterator{store: store, searchParams: searchParams, includeArchived: includeArchived, filters: filters, ctx: ctx}
+}
+
+// Next retrieves the next page, returning false once there are no more
+// Products or if retrieval fails; see Err.
+func (it *ProductIterator) Next() bool {
+  if it.done {
+    return false
+  }
+  page, restErr := it.store.ListPage(it.searchParams, it.includeArchived, it.filters, it.cursor, it.ctx)
+  if restErr != nil {
+    it.err, it.done = restErr, true
+    return false
+  }
+  it.page, it.cursor = page, page.Next
+  it.done = page.Next == ``
+  return len(page.Products) > 0
+}
+
+// Page returns the Products of the current page.
+func (it *ProductIterator) Page() []*Product {
+  if it.page == nil {
+    return nil
+  }
+  return it.page.Products
+}
+
+// Cursor returns the cursor for the page following the current page, from
+// which a later iterator may resume; empty once the walk is complete.
+func (it *ProductIterator) Cursor() string {
+  return it.cursor
+}
+
+// Err returns the error, if any, which ended the walk.
+func (it *ProductIterator) Err() rest.RestError {
+  return it.err
+}
+
+// Resume continues the walk from a cursor previously returned by Cursor.
+func (it *ProductIterator) Resume(cursor string) *ProductIterator {
+  it.cursor = cursor
+  return it
+}
@@ -0,0 +1,107 @@
+package products_test
+
+import (
+  "context"
+  "net/http"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func pageNames(products []*Product) []string {
+  names := make([]string, 0, len(products))
+  for _, p := range products {
+    names = append(names, p.DisplayName.String)
+  }
+  return names
+}
+
+func TestMemoryStoreListPage(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  // Duplicate names are ordered by ID.
+  for _, name := range []string{`Gizmo`, `Blog`, `Widget`, `Gizmo`, `Doohickey`} {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  searchParams := &rest.SearchParams{Sort: `name-asc`, PageInfo: &rest.PageInfo{ItemsPerPage: 2}}
+  page, restErr := store.ListPage(searchParams, false, nil, ``, ctx)
+  require.NoError(t, restErr, `Unexpected error listing first page.`)
+  assert.Equal(t, []string{`Blog`, `Doohickey`}, pageNames(page.Products), `Unexpected first page.`)
+  assert.Empty(t, page.Prev, `Unexpected 'prev' cursor on first page.`)
+  require.NotEmpty(t, page.Next, `Expected 'next' cursor.`)
+
+  second, restErr := store.ListPage(searchParams, false, nil, page.Next, ctx)
+  require.NoError(t, restErr, `Unexpected error listing second page.`)
+  assert.Equal(t, []string{`Gizmo`, `Gizmo`}, pageNames(second.Products), `Unexpected second page.`)
+  assert.True(t, second.Products[0].Id.Int64 < second.Products[1].Id.Int64, `Ties not ordered by ID.`)
+
+  last, restErr := store.ListPage(searchParams, false, nil, second.Next, ctx)
+  require.NoError(t, restErr, `Unexpected error listing last page.`)
+  assert.Equal(t, []string{`Widget`}, pageNames(last.Products), `Unexpected last page.`)
+  assert.Empty(t, last.Next, `Unexpected 'next' cursor on last page.`)
+
+  back, restErr := store.ListPage(searchParams, false, nil, last.Prev, ctx)
+  require.NoError(t, restErr, `Unexpected error listing previous page.`)
+  assert.Equal(t, pageNames(second.Products), pageNames(back.Products), `Unexpected previous page.`)
+  back, restErr = store.ListPage(searchParams, false, nil, back.Prev, ctx)
+  require.NoError(t, restErr, `Unexpected error listing previous page.`)
+  assert.Equal(t, pageNames(page.Products), pageNames(back.Products), `Unexpected previous page.`)
+  assert.Empty(t, back.Prev, `Unexpected 'prev' cursor on first page.`)
+  assert.NotEmpty(t, back.Next, `Expected 'next' cursor.`)
+
+  // Removing a Product does not shift the following page.
+  _, restErr = store.Delete(page.Products[0].PubId.String, ctx)
+  require.NoError(t, restErr, `Unexpected error deleting Product.`)
+  second, restErr = store.ListPage(searchParams, false, nil, page.Next, ctx)
+  require.NoError(t, restErr, `Unexpected error listing second page.`)
+  assert.Equal(t, []string{`Gizmo`, `Gizmo`}, pageNames(second.Products), `Unexpected second page after delete.`)
+
+  searchParams.Sort = `name-desc`
+  _, restErr = store.ListPage(searchParams, false, nil, page.Next, ctx)
+  require.Error(t, restErr, `Unexpected success with cursor for another sort.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+  _, restErr = store.ListPage(searchParams, false, nil, `not a cursor`, ctx)
+  require.Error(t, restErr, `Unexpected success with invalid cursor.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+}
+
+func TestProductIterator(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  for _, name := range []string{`Gizmo`, `Blog`, `Widget`, `Doohickey`, `Thingamajig`} {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  searchParams := &rest.SearchParams{Sort: `name-desc`, PageInfo: &rest.PageInfo{ItemsPerPage: 2}}
+  names, pages := make([]string, 0), 0
+  products := NewProductIterator(store, searchParams, false, nil, ctx)
+  for products.Next() {
+    pages += 1
+    names = append(names, pageNames(products.Page())...)
+    if pages == 1 {
+      // A new iterator picks up where this one leaves off.
+      resumed := NewProductIterator(store, searchParams, false, nil, ctx).Resume(products.Cursor())
+      require.True(t, resumed.Next(), `Expected resumed iterator to continue.`)
+      assert.Equal(t, []string{`Gizmo`, `Doohickey`}, pageNames(resumed.Page()), `Unexpected resumed page.`)
+    }
+  }
+  require.NoError(t, products.Err(), `Unexpected error iterating Products.`)
+  assert.Equal(t, 3, pages, `Unexpected number of pages.`)
+  assert.Equal(t, []string{`Widget`, `Thingamajig`, `Gizmo`, `Doohickey`, `Blog`}, names, `Unexpected Products.`)
+  assert.Empty(t, products.Cursor(), `Unexpected cursor after walk.`)
+
+  searchParams.Sort = `bad-sort`
+  products = NewProductIterator(store, searchParams, false, nil, ctx)
+  assert.False(t, products.Next(), `Unexpected page with unknown sort.`)
+  assert.Error(t, products.Err(), `Expected error on unknown sort.`)
+}
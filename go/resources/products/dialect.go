@@ -0,0 +1,137 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+  "strings"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// Dialect captures the differences in SQL between the supported databases.
+// The product statements are otherwise written in the common subset of SQL.
+type Dialect struct {
+  // Name identifies the dialect in log and error messages.
+  Name string
+  // placeholder, if set, formats the numbered placeholder replacing each '?'.
+  // See Rebind.
+  placeholder string
+  // likeOperator performs a case-insensitive LIKE.
+  likeOperator string
+  // lockClause is appended to a SELECT to lock the selected rows for the
+  // remainder of the transaction.
+  lockClause string
+  // touchExpr is the new 'entities.last_updated' value when a Product changes.
+  // It must always increase, even for changes within the same second, as it
+  // versions the Product; see checkProductVersionInTxn.
+  touchExpr string
+  // epochFormat converts the TIMESTAMP expression given as '%s' to seconds
+  // since the epoch.
+  epochFormat string
+  // fullText generates, for a full-text search term, the expressions which
+  // match and rank Products, each taking the returned parameters.
+  fullText func(term string, boolean bool) (match string, rank string, params []interface{})
+  // createEntityInTxn creates the 'entities' record for a new Product and
+  // returns the internal ID.
+  createEntityInTxn func(ctx context.Context, txn *sql.Tx) (int64, rest.RestError)
+  // adoptionProbes lists, for each of the leading migrations, queries which
+  // succeed only if the schema objects of the migration exist. See
+  // MigrateSchema.
+  adoptionProbes [][]string
+  // lockMigrations acquires a lock, held until the returned function is
+  // called, which excludes other instances from migrating the schema.
+  lockMigrations func(conn *sql.Conn, ctx context.Context) (func(), error)
+}
+
+// MySQLDialect is the default dialect. The 'entities' and 'users' tables are
+// managed by catalyst-core-api.
+var MySQLDialect = &Dialect{
+  Name: `mysql`,
+  likeOperator: `LIKE`,
+  lockClause: ` FOR UPDATE`,
+  touchExpr: `GREATEST(last_updated + 1, UNIX_TIMESTAMP())`,
+  epochFormat: `UNIX_TIMESTAMP(%s)`,
+  fullText: mysqlFullText,
+  createEntityInTxn: insertEntityInTxn,
+  // Migration 1 is the original products schema; migration 2 adds the
+  // 'archived' column and 'product_revisions' table.
+  adoptionProbes: [][]string{
+    {`SELECT 1 FROM products WHERE 1=0`},
+    {`SELECT archived FROM products WHERE 1=0`, `SELECT 1 FROM product_revisions WHERE 1=0`},
+  },
+  lockMigrations: lockMySQLMigrations,
+}
+
+const createEntityStatement = `INSERT INTO entities (pub_id) VALUES (?)`
+
+// insertEntityInTxn creates an entity, returning the internal ID, for the
+// dialects supporting LastInsertId. It stands in for the catalyst-core-api
+// 'entities.CreateEntityInTxn', which ignores the context; the other
+// 'entities' columns take their defaults.
+func insertEntityInTxn(ctx context.Context, txn *sql.Tx) (int64, rest.RestError) {
+  pubId, err := newPubId()
+  if err != nil {
+    return 0, rest.ServerError("Could not generate entity ID.", err)
+  }
+  result, err := txn.ExecContext(ctx, createEntityStatement, pubId)
+  if err != nil {
+    return 0, ClassifySQLError("Could not create entity.", err)
+  }
+  id, err := result.LastInsertId()
+  if err != nil {
+    return 0, ClassifySQLError("Could not create entity.", err)
+  }
+  return id, nil
+}
+
+// completeSchemaProbes identify a database created with the 'archived' column
+// and 'product_revisions' table, as were those created for PostgreSQL and
+// SQLite before migrations were introduced.
+var completeSchemaProbes = [][]string{
+  {`SELECT 1 FROM products WHERE 1=0`, `SELECT archived FROM products WHERE 1=0`, `SELECT 1 FROM product_revisions WHERE 1=0`},
+}
+
+// migrationLockTimeout is the time, in seconds, to wait on another instance
+// migrating the schema.
+const migrationLockTimeout = 300
+
+// The lock is held by the connection rather than a transaction.
+func lockMySQLMigrations(conn *sql.Conn, ctx context.Context) (func(), error) {
+  var locked sql.NullInt64
+  if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK('products_migrations', ?)`, migrationLockTimeout).Scan(&locked); err != nil {
+    return nil, err
+  } else if locked.Int64 != 1 {
+    return nil, fmt.Errorf("timed out waiting on lock 'products_migrations'")
+  }
+  return func() { conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK('products_migrations')`) }, nil
+}
+
+// Rebind converts a query written with '?' placeholders, as are all the
+// product statements, to the placeholder style of the dialect. Question marks
+// within quoted strings and identifiers are left alone.
+func (d *Dialect) Rebind(query string) string {
+  if d.placeholder == `` {
+    return query
+  }
+
+  var rebound strings.Builder
+  var quote rune
+  n := 0
+  for _, c := range query {
+    switch {
+    case quote != 0:
+      if c == quote {
+        quote = 0
+      }
+    case c == '\'' || c == '"' || c == '`':
+      quote = c
+    case c == '?':
+      n += 1
+      rebound.WriteString(fmt.Sprintf(d.placeholder, n))
+      continue
+    }
+    rebound.WriteRune(c)
+  }
+  return rebound.String()
+}
@@ -0,0 +1,17 @@
+package products_test
+
+import (
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/stretchr/testify/assert"
+)
+
+func TestDialectRebind(t *testing.T) {
+  query := "SELECT p.id FROM products p WHERE p.display_name LIKE ? AND p.summary<>'?' AND `p?`=? LIMIT ?"
+  assert.Equal(t, query, MySQLDialect.Rebind(query), `Unexpected MySQL query.`)
+  assert.Equal(t, query, SQLiteDialect.Rebind(query), `Unexpected SQLite query.`)
+  assert.Equal(t,
+    "SELECT p.id FROM products p WHERE p.display_name LIKE $1 AND p.summary<>'?' AND `p?`=$2 LIMIT $3",
+    PostgresDialect.Rebind(query), `Unexpected PostgreSQL query.`)
+}
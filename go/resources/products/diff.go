@@ -0,0 +1,67 @@
+package products
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "reflect"
+  "strings"
+)
+
+// FieldDiff reports the old and new values of a changed Product field. The
+// field is identified by its JSON name and the values are given as they would
+// appear in JSON, with nil for null.
+type FieldDiff struct {
+  Field string      `json:"field"`
+  Old   interface{} `json:"old"`
+  New   interface{} `json:"new"`
+}
+
+// DiffProducts compares each Product field, including those of the embedded
+// entities.Entity, and reports those which differ between 'a' and 'b' in
+// field order. Fields excluded from JSON, such as the internal ID, are not
+// compared.
+func DiffProducts(a, b *Product) []FieldDiff {
+  diffs := make([]FieldDiff, 0)
+  diffStructs(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), &diffs)
+  return diffs
+}
+
+func diffStructs(a, b reflect.Value, diffs *[]FieldDiff) {
+  for i := 0; i < a.NumField(); i++ {
+    fieldType := a.Type().Field(i)
+    name := strings.Split(fieldType.Tag.Get(`json`), `,`)[0]
+    if name == `-` || fieldType.PkgPath != `` { // excluded or unexported
+      continue
+    }
+    if fieldType.Anonymous && name == `` && fieldType.Type.Kind() == reflect.Struct {
+      diffStructs(a.Field(i), b.Field(i), diffs)
+      continue
+    }
+    if name == `` {
+      name = fieldType.Name
+    }
+
+    oldJSON, _ := json.Marshal(a.Field(i).Interface())
+    newJSON, _ := json.Marshal(b.Field(i).Interface())
+    if !bytes.Equal(oldJSON, newJSON) {
+      var oldVal, newVal interface{}
+      json.Unmarshal(oldJSON, &oldVal)
+      json.Unmarshal(newJSON, &newVal)
+      *diffs = append(*diffs, FieldDiff{name, oldVal, newVal})
+    }
+  }
+}
+
+// FormatDiffText renders the diffs in unified diff style, one line per old and
+// new field value, with values given as JSON.
+func FormatDiffText(fromLabel string, toLabel string, diffs []FieldDiff) string {
+  var text strings.Builder
+  fmt.Fprintf(&text, "--- %s\n+++ %s\n", fromLabel, toLabel)
+  for _, diff := range diffs {
+    oldJSON, _ := json.Marshal(diff.Old)
+    newJSON, _ := json.Marshal(diff.New)
+    fmt.Fprintf(&text, "@@ %s @@\n-%s: %s\n+%s: %s\n", diff.Field, diff.Field, oldJSON, diff.Field, newJSON)
+  }
+  return text.String()
+}
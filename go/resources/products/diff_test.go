@@ -0,0 +1,37 @@
+package products_test
+
+import (
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestDiffProducts(t *testing.T) {
+  assert.Empty(t, DiffProducts(widgetProduct, widgetProduct.Clone()), `Unexpected diffs for clone.`)
+
+  changed := widgetProduct.Clone()
+  changed.Id = nulls.NewInt64(10)
+  changed.PubId = nulls.NewString(`b`)
+  changed.SetDisplayName(`Wodget`)
+  changed.RepoURL = nulls.NewNullString()
+
+  diffs := DiffProducts(widgetProduct, changed)
+  require.Len(t, diffs, 3, `Unexpected number of diffs; internal ID should be ignored.`)
+  assert.Equal(t, FieldDiff{`pubId`, `a`, `b`}, diffs[0], `Unexpected entity field diff.`)
+  assert.Equal(t, FieldDiff{`displayName`, `Widget`, `Wodget`}, diffs[1], `Unexpected display name diff.`)
+  assert.Equal(t, FieldDiff{`repoURL`, `https://foo.com/products/widget/repo`, nil}, diffs[2], `Unexpected null diff.`)
+}
+
+func TestFormatDiffText(t *testing.T) {
+  changed := widgetProduct.Clone()
+  changed.SetDisplayName(`Wodget`)
+  changed.SupportPhone = nulls.NewNullString()
+
+  expected := "--- revision 1\n+++ revision 2\n" +
+    "@@ displayName @@\n-displayName: \"Widget\"\n+displayName: \"Wodget\"\n" +
+    "@@ supportPhone @@\n-supportPhone: \"555-555-9999\"\n+supportPhone: null\n"
+  assert.Equal(t, expected, FormatDiffText(`revision 1`, `revision 2`, DiffProducts(widgetProduct, changed)))
+}
@@ -0,0 +1,67 @@
+package products
+
+import (
+  "net/http"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// productError implements rest.RestError for those HTTP statuses not covered
+// by the 'rest' package constructors.
+type productError struct {
+  message string
+  code    int
+  cause   error
+}
+
+func (e productError) Error() string {
+  return e.message
+}
+
+func (e productError) Code() int {
+  return e.code
+}
+
+func (e productError) Cause() error {
+  return e.cause
+}
+
+// conflictError indicates the request cannot be applied to the current state
+// of the resource; e.g., the Product was modified since it was last read.
+func conflictError(message string, cause error) rest.RestError {
+  return productError{message, http.StatusConflict, cause}
+}
+
+// preconditionRequiredError indicates a conditional request is required, but
+// no condition (e.g., 'lastUpdated' or 'If-Match') was provided.
+func preconditionRequiredError(message string, cause error) rest.RestError {
+  return productError{message, http.StatusPreconditionRequired, cause}
+}
+
+// unsupportedMediaTypeError indicates the request body is in an unsupported
+// format.
+func unsupportedMediaTypeError(message string, cause error) rest.RestError {
+  return productError{message, http.StatusUnsupportedMediaType, cause}
+}
+
+// StatusClientClosedRequest is the non-standard, but widely used, status
+// indicating the client went away before the request completed.
+const StatusClientClosedRequest = 499
+
+// canceledError indicates the request context was canceled; e.g., because the
+// client disconnected.
+func canceledError(message string, cause error) rest.RestError {
+  return productError{message, StatusClientClosedRequest, cause}
+}
+
+// timeoutError indicates the operation did not complete within the allotted
+// time; see QueryTimeout.
+func timeoutError(message string, cause error) rest.RestError {
+  return productError{message, http.StatusGatewayTimeout, cause}
+}
+
+// unavailableError indicates the products store is not set up; see
+// StoreUnavailable.
+func unavailableError(message string, cause error) rest.RestError {
+  return productError{message, http.StatusServiceUnavailable, cause}
+}
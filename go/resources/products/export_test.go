@@ -0,0 +1,19 @@
+package products
+
+import (
+  "context"
+  "net/http"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// AuthenticateAs replaces the authentication check, for testing the handlers,
+// with one accepting every request as from the user 'uid'. The returned
+// function restores the check.
+func AuthenticateAs(uid string) (restore func()) {
+  check := authCheck
+  authCheck = func(w http.ResponseWriter, r *http.Request) (context.Context, rest.RestError) {
+    return ContextWithActor(r.Context(), uid), nil
+  }
+  return func() { authCheck = check }
+}
@@ -0,0 +1,166 @@
+package products
+
+import (
+  "context"
+  "sort"
+
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// ProductFacets summarize a listing for filtering: the number of matching
+// Products for each value of the 'ontology', 'legalOwner', 'hasRepoURL', and
+// 'hasIssuesURL' filters. The counts are for the listing as a whole, not just
+// the current page, and reflect every filter in effect, including the faceted
+// filter itself.
+type ProductFacets struct {
+  // Ontology counts the Products per ontology, most frequent first. Products
+  // without an ontology are not counted.
+  Ontology     []*OntologyFacet   `json:"ontology"`
+  // LegalOwner counts the Products per legal owner, most frequent first.
+  LegalOwner   []*LegalOwnerFacet `json:"legalOwner"`
+  HasRepoURL   PresenceFacet      `json:"hasRepoURL"`
+  HasIssuesURL PresenceFacet      `json:"hasIssuesURL"`
+}
+
+// OntologyFacet is the number of Products with the ontology.
+type OntologyFacet struct {
+  Ontology string `json:"ontology"`
+  Count    int64  `json:"count"`
+}
+
+// LegalOwnerFacet is the number of Products of the legal owner, along with
+// the legal owner details for display.
+type LegalOwnerFacet struct {
+  PubID       string       `json:"pubId"`
+  LegalID     nulls.String `json:"legalID"`
+  LegalIDType nulls.String `json:"legalIDType"`
+  Count       int64        `json:"count"`
+}
+
+// PresenceFacet is the number of Products with, and without, an optional
+// field.
+type PresenceFacet struct {
+  Present int64 `json:"true"`
+  Absent  int64 `json:"false"`
+}
+
+// sortFacets orders the per value facets by descending count, and then by
+// value.
+func (f *ProductFacets) sortFacets() {
+  sort.Slice(f.Ontology, func(i, j int) bool {
+    a, b := f.Ontology[i], f.Ontology[j]
+    return a.Count > b.Count || (a.Count == b.Count && a.Ontology < b.Ontology)
+  })
+  sort.Slice(f.LegalOwner, func(i, j int) bool {
+    a, b := f.LegalOwner[i], f.LegalOwner[j]
+    return a.Count > b.Count || (a.Count == b.Count && a.PubID < b.PubID)
+  })
+}
+
+// count implements the facet queries for the MemoryStore.
+func (f *ProductFacets) count(p *Product) {
+  if ontology := p.Ontology.String; ontology != `` {
+    var facet *OntologyFacet
+    for _, candidate := range f.Ontology {
+      if candidate.Ontology == ontology {
+        facet = candidate
+      }
+    }
+    if facet == nil {
+      facet = &OntologyFacet{Ontology: ontology}
+      f.Ontology = append(f.Ontology, facet)
+    }
+    facet.Count += 1
+  }
+
+  var owner *LegalOwnerFacet
+  for _, candidate := range f.LegalOwner {
+    if candidate.PubID == p.LegalOwnerPubID.String {
+      owner = candidate
+    }
+  }
+  if owner == nil {
+    owner = &LegalOwnerFacet{PubID: p.LegalOwnerPubID.String, LegalID: nulls.NewNullString(), LegalIDType: nulls.NewNullString()}
+    f.LegalOwner = append(f.LegalOwner, owner)
+  }
+  owner.Count += 1
+
+  for _, presence := range []struct{
+    value  nulls.String
+    facet  *PresenceFacet
+  }{{p.RepoURL, &f.HasRepoURL}, {p.IssuesURL, &f.HasIssuesURL}} {
+    if presence.value.Valid && presence.value.String != `` {
+      presence.facet.Present += 1
+    } else {
+      presence.facet.Absent += 1
+    }
+  }
+}
+
+// ListProductFacets counts the Products matching the search terms and filters
+// by facet; see ProductFacets. The search is as for ListProducts, but the
+// search parameters are not modified.
+func ListProductFacets(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) (*ProductFacets, rest.RestError) {
+  bits, restErr := buildSearchBits(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
+
+  facets := &ProductFacets{Ontology: make([]*OntologyFacet, 0), LegalOwner: make([]*LegalOwnerFacet, 0)}
+  // A MySQL ENUM may hold the empty 'error' value, which is skipped below
+  // rather than in the query, as Postgres rejects '' as an ENUM literal.
+  ontologyQuery := `SELECT p.ontology, COUNT(*) ` + CommonProductsFrom + bits.where + `AND p.ontology IS NOT NULL GROUP BY p.ontology`
+  rows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(ontologyQuery), bits.params...)
+  if err != nil {
+    return nil, ClassifySQLError("Could not count products by ontology.", err)
+  }
+  defer rows.Close()
+  for rows.Next() {
+    facet := &OntologyFacet{}
+    if err := rows.Scan(&facet.Ontology, &facet.Count); err != nil {
+      return nil, rest.ServerError("Problem getting ontology counts.", err)
+    }
+    if facet.Ontology != `` {
+      facets.Ontology = append(facets.Ontology, facet)
+    }
+  }
+  if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Could not count products by ontology.", err)
+  }
+
+  // Outer join, so the Products are counted even if the users record is
+  // missing.
+  ownerQuery := `SELECT lo.pub_id, u.legal_id, u.legal_id_type, COUNT(*) ` + CommonProductsFrom + `LEFT JOIN users u ON lo.id=u.id ` + bits.where + `GROUP BY lo.pub_id, u.legal_id, u.legal_id_type`
+  ownerRows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(ownerQuery), bits.params...)
+  if err != nil {
+    return nil, ClassifySQLError("Could not count products by legal owner.", err)
+  }
+  defer ownerRows.Close()
+  for ownerRows.Next() {
+    facet := &LegalOwnerFacet{}
+    if err := ownerRows.Scan(&facet.PubID, &facet.LegalID, &facet.LegalIDType, &facet.Count); err != nil {
+      return nil, rest.ServerError("Problem getting legal owner counts.", err)
+    }
+    facets.LegalOwner = append(facets.LegalOwner, facet)
+  }
+  if err := ownerRows.Err(); err != nil {
+    return nil, ClassifySQLError("Could not count products by legal owner.", err)
+  }
+
+  var total int64
+  presenceQuery := `SELECT COUNT(*), ` +
+    `COALESCE(SUM(CASE WHEN ` + presenceBit(`p.repo_url`, true) + ` THEN 1 ELSE 0 END), 0), ` +
+    `COALESCE(SUM(CASE WHEN ` + presenceBit(`p.issues_url`, true) + ` THEN 1 ELSE 0 END), 0) ` +
+    CommonProductsFrom + bits.where
+  if err := productsDB().QueryRowContext(ctx, sqlDialect().Rebind(presenceQuery), bits.params...).Scan(&total, &facets.HasRepoURL.Present, &facets.HasIssuesURL.Present); err != nil {
+    return nil, ClassifySQLError("Could not count products by presence.", err)
+  }
+  facets.HasRepoURL.Absent = total - facets.HasRepoURL.Present
+  facets.HasIssuesURL.Absent = total - facets.HasIssuesURL.Present
+
+  facets.sortFacets()
+  return facets, nil
+}
@@ -0,0 +1,49 @@
+package products_test
+
+import (
+  "context"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreFacets(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  for _, name := range []string{`Gizmo`, `Gadget`, `Widget`} {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    if name == `Gizmo` {
+      p.SetOntology(`SOFTWARE SERVICE`)
+      p.SetRepoURL(``)
+    }
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  searchParams := &rest.SearchParams{PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 1}}
+  facets, restErr := store.Facets(searchParams, false, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Equal(t, []*OntologyFacet{{`TANGIBLE GOOD`, 2}, {`SOFTWARE SERVICE`, 1}}, facets.Ontology, `Unexpected ontology facets.`)
+  require.Len(t, facets.LegalOwner, 1, `Unexpected legal owner facets.`)
+  assert.Equal(t, `4C2B3954-8D7F-48BA-B720-3B0F15F91BA9`, facets.LegalOwner[0].PubID, `Unexpected legal owner.`)
+  assert.Equal(t, int64(3), facets.LegalOwner[0].Count, `Unexpected legal owner count.`)
+  assert.Equal(t, PresenceFacet{Present: 2, Absent: 1}, facets.HasRepoURL, `Unexpected repo facet.`)
+  assert.Equal(t, PresenceFacet{Present: 3, Absent: 0}, facets.HasIssuesURL, `Unexpected issues facet.`)
+
+  // The counts follow the search and filters.
+  searchParams.Terms = []string{`g`}
+  facets, restErr = store.Facets(searchParams, false, &ProductFilters{Present: map[string]bool{`repoURL`: true}}, ctx)
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Equal(t, []*OntologyFacet{{`TANGIBLE GOOD`, 2}}, facets.Ontology, `Unexpected filtered ontology facets.`)
+  assert.Equal(t, PresenceFacet{Present: 2, Absent: 0}, facets.HasRepoURL, `Unexpected filtered repo facet.`)
+
+  searchParams.Terms = []string{`nothing like it`}
+  facets, restErr = store.Facets(searchParams, false, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Empty(t, facets.Ontology, `Unexpected ontology facets without matches.`)
+  assert.Empty(t, facets.LegalOwner, `Unexpected legal owner facets without matches.`)
+}
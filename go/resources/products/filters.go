@@ -0,0 +1,179 @@
+package products
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// ProductFilters narrow a listing beyond the search terms. The zero value, like
+// a nil *ProductFilters, filters nothing. The filter values are only ever
+// passed to the database as parameters; the SQL itself is built from the
+// allowed fields and fixed expressions.
+type ProductFilters struct {
+  // Ontologies, if not empty, limits the listing to Products with any of the
+  // given ProductOntologies.
+  Ontologies         []string
+  // LegalOwnerPubID, if set, limits the listing to Products of the legal owner.
+  LegalOwnerPubID    string
+  // Present maps any of the PresenceFilterFields to whether the field must be
+  // set (true) or unset (false).
+  Present            map[string]bool
+  // UpdatedSince, if not zero, limits the listing to Products last updated at
+  // or after the time, in seconds since the epoch.
+  UpdatedSince       int64
+  // SupportEmailDomain, if set, limits the listing to Products with a support
+  // email address at exactly the domain, ignoring case.
+  SupportEmailDomain string
+  // SearchMode selects how the search terms are matched; one of SearchModes,
+  // or empty for SearchSubstring. Though not a filter as such, it accompanies
+  // the filters to each listing.
+  SearchMode         string
+}
+
+// PresenceFilterFields lists the optional Product fields which may be filtered
+// on presence.
+var PresenceFilterFields = []string{`repoURL`, `issuesURL`, `logoURL`}
+
+var uuidRegexp = regexp.MustCompile(`^` + uuidRE + `$`)
+var domainRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// normalize checks the filter values, returning a rest.BadRequestError
+// describing the first problem found. The legal owner and domain are converted
+// to their canonical case and the default search mode is set.
+func (f *ProductFilters) normalize() rest.RestError {
+  for _, ontology := range f.Ontologies {
+    if !isOntology(ontology) {
+      return rest.BadRequestError(fmt.Sprintf(`Unknown ontology '%s'; must be one of '%s'.`, ontology, strings.Join(ProductOntologies, `', '`)), nil)
+    }
+  }
+  if f.LegalOwnerPubID != `` {
+    if !uuidRegexp.MatchString(f.LegalOwnerPubID) {
+      return rest.BadRequestError(fmt.Sprintf(`Invalid legal owner '%s'; must be a public ID.`, f.LegalOwnerPubID), nil)
+    }
+    f.LegalOwnerPubID = strings.ToUpper(f.LegalOwnerPubID)
+  }
+  for field := range f.Present {
+    if !isPresenceFilterField(field) {
+      return rest.BadRequestError(fmt.Sprintf(`Cannot filter on presence of '%s'; must be one of '%s'.`, field, strings.Join(PresenceFilterFields, `', '`)), nil)
+    }
+  }
+  if f.UpdatedSince < 0 {
+    return rest.BadRequestError(fmt.Sprintf(`Invalid updated since %d; must not be negative.`, f.UpdatedSince), nil)
+  }
+  if f.SearchMode == `` {
+    f.SearchMode = SearchSubstring
+  } else if !isSearchMode(f.SearchMode) {
+    return rest.BadRequestError(fmt.Sprintf(`Unknown search mode '%s'; must be one of '%s'.`, f.SearchMode, strings.Join(SearchModes, `', '`)), nil)
+  }
+  if f.SupportEmailDomain != `` {
+    domain := strings.ToLower(f.SupportEmailDomain)
+    if !domainRegexp.MatchString(domain) {
+      return rest.BadRequestError(fmt.Sprintf(`Invalid support email domain '%s'.`, f.SupportEmailDomain), nil)
+    }
+    f.SupportEmailDomain = domain
+  }
+  return nil
+}
+
+func isSearchMode(mode string) bool {
+  for _, allowed := range SearchModes {
+    if mode == allowed {
+      return true
+    }
+  }
+  return false
+}
+
+func isPresenceFilterField(field string) bool {
+  for _, allowed := range PresenceFilterFields {
+    if field == allowed {
+      return true
+    }
+  }
+  return false
+}
+
+// bits generates the WHERE clause for the filters. The SQL is composed only of
+// fixed expressions and the 'products' column of each allowed presence field.
+func (f *ProductFilters) bits(params []interface{}) (string, []interface{}, rest.RestError) {
+  if f == nil {
+    return ``, params, nil
+  }
+  if restErr := f.normalize(); restErr != nil {
+    return ``, nil, restErr
+  }
+
+  var whereBit string
+  if len(f.Ontologies) > 0 {
+    whereBit += `AND p.ontology IN (?` + strings.Repeat(`,?`, len(f.Ontologies) - 1) + `) `
+    for _, ontology := range f.Ontologies {
+      params = append(params, ontology)
+    }
+  }
+  if f.LegalOwnerPubID != `` {
+    whereBit += `AND lo.pub_id=? `
+    params = append(params, f.LegalOwnerPubID)
+  }
+  for _, name := range PresenceFilterFields { // in a fixed order
+    if present, ok := f.Present[name]; ok {
+      field, _ := findProductField(name)
+      whereBit += `AND ` + presenceBit(`p.` + field.column, present) + ` `
+    }
+  }
+  if f.UpdatedSince != 0 {
+    whereBit += `AND e.last_updated>=? `
+    params = append(params, f.UpdatedSince)
+  }
+  if f.SupportEmailDomain != `` {
+    // The domain is limited to letters, digits, '-', and '.', so it cannot
+    // contain LIKE wildcards.
+    whereBit += `AND LOWER(p.support_email) LIKE ? `
+    params = append(params, `%@` + f.SupportEmailDomain)
+  }
+  return whereBit, params, nil
+}
+
+// presenceBit generates the condition that the column is set (not NULL or
+// empty) or, if 'present' is false, unset.
+func presenceBit(column string, present bool) string {
+  if present {
+    return fmt.Sprintf(`(%[1]s IS NOT NULL AND %[1]s<>'')`, column)
+  }
+  return fmt.Sprintf(`(%[1]s IS NULL OR %[1]s='')`, column)
+}
+
+// matches implements 'bits' for the MemoryStore. The filters must be
+// normalized.
+func (f *ProductFilters) matches(p *Product) bool {
+  if f == nil {
+    return true
+  }
+  if len(f.Ontologies) > 0 {
+    found := false
+    for _, ontology := range f.Ontologies {
+      found = found || p.Ontology.String == ontology
+    }
+    if !found {
+      return false
+    }
+  }
+  if f.LegalOwnerPubID != `` && strings.ToUpper(p.LegalOwnerPubID.String) != f.LegalOwnerPubID {
+    return false
+  }
+  for name, present := range f.Present {
+    field, _ := findProductField(name)
+    if value := field.value(p); (value.Valid && value.String != ``) != present {
+      return false
+    }
+  }
+  if f.UpdatedSince != 0 && p.LastUpdated.Int64 < f.UpdatedSince {
+    return false
+  }
+  if f.SupportEmailDomain != `` && !strings.HasSuffix(strings.ToLower(p.SupportEmail.String), `@` + f.SupportEmailDomain) {
+    return false
+  }
+  return true
+}
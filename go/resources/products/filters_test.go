@@ -0,0 +1,54 @@
+package products_test
+
+import (
+  "context"
+  "net/http"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreFilters(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  gizmo := widgetProduct.Clone()
+  gizmo.SetDisplayName(`Gizmo`)
+  gizmo.SetOntology(`SOFTWARE SERVICE`)
+  gizmo.SetSupportEmail(`help@Gizmo.org`)
+  gizmo.SetRepoURL(``)
+  for _, p := range []*Product{widgetProduct, gizmo} {
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  list := func(filters *ProductFilters) []string {
+    searchParams := &rest.SearchParams{PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+    products, restErr := store.List(searchParams, false, filters, ctx)
+    require.NoError(t, restErr, `Unexpected error listing Products.`)
+    return pageNames(products)
+  }
+  assert.Equal(t, []string{`Gizmo`, `Widget`}, list(nil), `Unexpected unfiltered Products.`)
+  assert.Equal(t, []string{`Gizmo`}, list(&ProductFilters{Ontologies: []string{`SOFTWARE SERVICE`}}), `Unexpected Products by ontology.`)
+  assert.Equal(t, []string{`Gizmo`, `Widget`}, list(&ProductFilters{Ontologies: []string{`SOFTWARE SERVICE`, `TANGIBLE GOOD`}}), `Unexpected Products by ontologies.`)
+  assert.Equal(t, []string{`Widget`}, list(&ProductFilters{Present: map[string]bool{`repoURL`: true}}), `Unexpected Products with repo.`)
+  assert.Equal(t, []string{`Gizmo`}, list(&ProductFilters{Present: map[string]bool{`repoURL`: false, `issuesURL`: true}}), `Unexpected Products without repo.`)
+  assert.Equal(t, []string{`Gizmo`}, list(&ProductFilters{SupportEmailDomain: `gizmo.ORG`}), `Unexpected Products by email domain.`)
+  assert.Empty(t, list(&ProductFilters{SupportEmailDomain: `izmo.org`}), `Unexpected Products by partial email domain.`)
+  assert.Equal(t, []string{`Gizmo`, `Widget`}, list(&ProductFilters{LegalOwnerPubID: `4c2b3954-8d7f-48ba-b720-3b0f15f91ba9`}), `Unexpected Products by legal owner.`)
+  assert.Empty(t, list(&ProductFilters{UpdatedSince: 1 << 40}), `Unexpected Products updated in the future.`)
+
+  searchParams := &rest.SearchParams{PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+  for _, filters := range []*ProductFilters{
+    {Ontologies: []string{`VAPORWARE`}},
+    {LegalOwnerPubID: `nobody`},
+    {Present: map[string]bool{`homepage`: true}},
+    {SupportEmailDomain: `%.com`},
+  } {
+    _, restErr := store.List(searchParams, false, filters, ctx)
+    require.Error(t, restErr, `Unexpected success with invalid filters %+v.`, filters)
+    assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+  }
+}
@@ -0,0 +1,267 @@
+package products
+
+import (
+  "html"
+  "regexp"
+  "sort"
+  "strings"
+  "unicode/utf8"
+)
+
+// The search modes select how the search terms are matched; see
+// ProductFilters.
+const (
+  // SearchSubstring matches Products with the term anywhere in the display
+  // name or summary; see ProductsGeneralWhereGenerator. This is the default.
+  SearchSubstring = `substring`
+  // SearchNatural matches Products with any of the words of the term, using
+  // the full-text index, and ranks them by relevance.
+  SearchNatural = `natural`
+  // SearchBoolean is like SearchNatural, but words may be required ('+word'),
+  // excluded ('-word'), or matched by prefix ('word*'), and phrases quoted.
+  SearchBoolean = `boolean`
+)
+
+// SearchModes lists the supported search modes.
+var SearchModes = []string{SearchSubstring, SearchNatural, SearchBoolean}
+
+// fullTextWord is a word, or quoted phrase, of a full-text search term. Only
+// letters and digits are retained, so the text never contains syntax.
+type fullTextWord struct {
+  text     string
+  // operator is '+' if the word is required, '-' if excluded, and 0 otherwise.
+  operator byte
+  prefix   bool
+}
+
+var wordRegexp = regexp.MustCompile(`[\p{L}\p{N}]+`)
+var booleanWordRegexp = regexp.MustCompile(`([+-]?)(?:"([^"]*)"?|([\p{L}\p{N}]+)(\*?))`)
+
+// parseFullTextTerm splits the term into words. In boolean mode, the
+// operators, prefix matches, and quoted phrases are recognized.
+func parseFullTextTerm(term string, boolean bool) []fullTextWord {
+  words := make([]fullTextWord, 0)
+  if !boolean {
+    for _, text := range wordRegexp.FindAllString(term, -1) {
+      words = append(words, fullTextWord{text: text})
+    }
+    return words
+  }
+
+  for _, match := range booleanWordRegexp.FindAllStringSubmatch(term, -1) {
+    word := fullTextWord{text: match[3], prefix: match[4] == `*`}
+    if match[1] != `` {
+      word.operator = match[1][0]
+    }
+    if word.text == `` { // a phrase
+      word.text = strings.Join(wordRegexp.FindAllString(match[2], -1), ` `)
+    }
+    if word.text != `` {
+      words = append(words, word)
+    }
+  }
+  return words
+}
+
+// mysqlFullText implements Dialect.fullText using the 'products_fulltext'
+// index; the boolean syntax is native to MySQL.
+func mysqlFullText(term string, boolean bool) (string, string, []interface{}) {
+  mode := `IN NATURAL LANGUAGE MODE`
+  if boolean {
+    mode = `IN BOOLEAN MODE`
+  }
+  match := `MATCH (p.display_name, p.summary) AGAINST (? ` + mode + `)`
+  return match, match, []interface{}{term}
+}
+
+// postgresFullText implements Dialect.fullText using the 'products_fulltext'
+// index. In boolean mode, the term is interpreted by 'websearch_to_tsquery',
+// which accepts quoted phrases, 'or', and '-word'.
+func postgresFullText(term string, boolean bool) (string, string, []interface{}) {
+  vector := `to_tsvector('english', p.display_name || ' ' || p.summary)`
+  query := `plainto_tsquery('english', ?)`
+  if boolean {
+    query = `websearch_to_tsquery('english', ?)`
+  }
+  return vector + ` @@ ` + query, `ts_rank(` + vector + `, ` + query + `)`, []interface{}{term}
+}
+
+// sqliteFullText implements Dialect.fullText using the 'products_fts' index.
+// The term is translated to an FTS5 query following the MySQL semantics.
+func sqliteFullText(term string, boolean bool) (string, string, []interface{}) {
+  query := fts5Query(parseFullTextTerm(term, boolean))
+  if query == `` {
+    return `1=0`, `0`, nil
+  }
+  // bm25 is lower for better matches
+  return `p.id IN (SELECT rowid FROM products_fts WHERE products_fts MATCH ?)`,
+    `COALESCE((SELECT -bm25(products_fts) FROM products_fts WHERE products_fts MATCH ? AND rowid=p.id), 0)`,
+    []interface{}{query}
+}
+
+// fts5Query builds the FTS5 query for the words. As with MySQL, when any word
+// is required the others only affect relevance, which is ignored here.
+func fts5Query(words []fullTextWord) string {
+  var required, optional, excluded []string
+  for _, word := range words {
+    quoted := `"` + strings.ReplaceAll(word.text, `"`, `""`) + `"`
+    if word.prefix {
+      quoted += `*`
+    }
+    switch word.operator {
+    case '+':
+      required = append(required, quoted)
+    case '-':
+      excluded = append(excluded, quoted)
+    default:
+      optional = append(optional, quoted)
+    }
+  }
+
+  positive, conjunction := required, ` AND `
+  if len(required) == 0 {
+    positive, conjunction = optional, ` OR `
+  }
+  if len(positive) == 0 {
+    return ``
+  }
+  query := `(` + strings.Join(positive, conjunction) + `)`
+  for _, word := range excluded {
+    query += ` NOT ` + word
+  }
+  return query
+}
+
+// matchSpans finds the byte offsets of each occurrence in 'text' of any of
+// the words, ignoring case. Excluded words are ignored. The spans are in
+// order and do not overlap.
+func matchSpans(text string, words []fullTextWord) [][2]int {
+  tokens := wordRegexp.FindAllStringIndex(text, -1)
+  lower := make([]string, len(tokens))
+  for i, token := range tokens {
+    lower[i] = strings.ToLower(text[token[0]:token[1]])
+  }
+
+  spans := make([][2]int, 0)
+  for _, word := range words {
+    if word.operator == '-' {
+      continue
+    }
+    parts := strings.Fields(strings.ToLower(word.text))
+    for i := 0; i + len(parts) <= len(tokens); i++ {
+      matched := true
+      for k, part := range parts {
+        if last := k == len(parts) - 1; lower[i + k] != part && !(last && word.prefix && strings.HasPrefix(lower[i + k], part)) {
+          matched = false
+          break
+        }
+      }
+      if matched {
+        spans = append(spans, [2]int{tokens[i][0], tokens[i + len(parts) - 1][1]})
+      }
+    }
+  }
+
+  sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+  merged := make([][2]int, 0, len(spans))
+  for _, span := range spans {
+    if n := len(merged); n > 0 && span[0] <= merged[n - 1][1] {
+      if span[1] > merged[n - 1][1] {
+        merged[n - 1][1] = span[1]
+      }
+    } else {
+      merged = append(merged, span)
+    }
+  }
+  return merged
+}
+
+// snippetLength is the approximate length, in bytes, of a highlighted
+// snippet, of which about snippetContext precedes the first match.
+const snippetLength = 160
+const snippetContext = 40
+
+// highlightSnippet excerpts the text around the first match of the words,
+// escaped for HTML with each match wrapped in '<mark>'. The empty string is
+// returned if nothing matches.
+func highlightSnippet(text string, words []fullTextWord) string {
+  spans := matchSpans(text, words)
+  if len(spans) == 0 {
+    return ``
+  }
+
+  // Start and end between words where possible.
+  first := spans[0]
+  start, end := first[0] - snippetContext, first[0] - snippetContext + snippetLength
+  if start <= 0 {
+    start = 0
+  } else if i := strings.IndexByte(text[start:first[0]], ' '); i >= 0 {
+    start += i + 1
+  }
+  if end >= len(text) {
+    end = len(text)
+  } else if i := strings.LastIndexByte(text[first[1]:end], ' '); i >= 0 {
+    end = first[1] + i
+  } else if end < first[1] {
+    end = first[1]
+  }
+  for start > 0 && !utf8.RuneStart(text[start]) {
+    start--
+  }
+  for end < len(text) && !utf8.RuneStart(text[end]) {
+    end++
+  }
+
+  var snippet strings.Builder
+  if start > 0 {
+    snippet.WriteString(`…`)
+  }
+  at := start
+  for _, span := range spans {
+    if span[0] >= end {
+      break
+    }
+    spanEnd := span[1]
+    if spanEnd > end {
+      spanEnd = end
+    }
+    snippet.WriteString(html.EscapeString(text[at:span[0]]))
+    snippet.WriteString(`<mark>` + html.EscapeString(text[span[0]:spanEnd]) + `</mark>`)
+    at = spanEnd
+  }
+  snippet.WriteString(html.EscapeString(text[at:end]))
+  if end < len(text) {
+    snippet.WriteString(`…`)
+  }
+  return snippet.String()
+}
+
+// matchesFullText implements the dialect full-text match for the
+// MemoryStore.
+func matchesFullText(p *Product, words []fullTextWord) bool {
+  text := p.DisplayName.String + ` ` + p.Summary.String
+  hasRequired, anyOptional := false, false
+  for _, word := range words {
+    found := len(matchSpans(text, []fullTextWord{{text: word.text, prefix: word.prefix}})) > 0
+    switch word.operator {
+    case '+':
+      if !found {
+        return false
+      }
+      hasRequired = true
+    case '-':
+      if found {
+        return false
+      }
+    default:
+      anyOptional = anyOptional || found
+    }
+  }
+  return hasRequired || anyOptional
+}
+
+// fullTextRelevance implements the dialect full-text relevance for the
+// MemoryStore, weighting matches in the display name over the summary.
+func fullTextRelevance(p *Product, words []fullTextWord) int {
+  return 2 * len(matchSpans(p.DisplayName.String, words)) + len(matchSpans(p.Summary.String, words))
+}
@@ -0,0 +1,57 @@
+package products_test
+
+import (
+  "context"
+  "net/http"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreFullText(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  for name, summary := range map[string]string{
+    `Gizmo`: `A gadget for <your> desk.`,
+    `Gadget Gadget`: `The gadget of gadgets.`,
+    `Doohickey`: `Gadgetry for the desk & wall.`,
+  } {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    p.SetSummary(summary)
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  list := func(term string, mode string, sort string) []*Product {
+    searchParams := &rest.SearchParams{Terms: []string{term}, Sort: sort, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+    products, restErr := store.List(searchParams, false, &ProductFilters{SearchMode: mode}, ctx)
+    require.NoError(t, restErr, `Unexpected error searching Products.`)
+    return products
+  }
+
+  products := list(`gadget`, SearchNatural, `relevance`)
+  assert.Equal(t, []string{`Gadget Gadget`, `Gizmo`}, pageNames(products), `Unexpected Products by relevance.`)
+  assert.Equal(t, `A <mark>gadget</mark> for &lt;your&gt; desk.`, products[1].Snippet, `Unexpected snippet.`)
+  assert.Equal(t, []string{`Gadget Gadget`, `Gizmo`}, pageNames(list(`gadget`, SearchNatural, ``)), `Unexpected Products by name.`)
+  assert.Equal(t, []string{`Doohickey`, `Gadget Gadget`, `Gizmo`}, pageNames(list(`gadget*`, SearchBoolean, ``)), `Unexpected Products by prefix.`)
+  assert.Equal(t, []string{`Doohickey`, `Gizmo`}, pageNames(list(`+desk`, SearchBoolean, ``)), `Unexpected Products with required word.`)
+  assert.Equal(t, []string{`Gizmo`}, pageNames(list(`+desk -wall`, SearchBoolean, ``)), `Unexpected Products with excluded word.`)
+  assert.Equal(t, []string{`Gizmo`}, pageNames(list(`"for your desk"`, SearchBoolean, ``)), `Unexpected Products by phrase.`)
+  // Substring searches match within words and have no snippets.
+  products = list(`gadget`, ``, ``)
+  assert.Len(t, products, 3, `Unexpected number of Products by substring.`)
+  assert.Empty(t, products[0].Snippet, `Unexpected snippet for substring search.`)
+
+  searchParams := &rest.SearchParams{Terms: []string{`gadget`}, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+  _, restErr := store.List(searchParams, false, &ProductFilters{SearchMode: `fuzzy`}, ctx)
+  require.Error(t, restErr, `Unexpected success with unknown search mode.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+  searchParams.Sort = `relevance`
+  _, restErr = store.ListPage(searchParams, false, &ProductFilters{SearchMode: SearchNatural}, ``, ctx)
+  require.Error(t, restErr, `Unexpected success paging by relevance.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+}
@@ -0,0 +1,333 @@
+package products
+
+import (
+  "context"
+  "crypto/rand"
+  "fmt"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// MemoryStore is a ProductStore holding Products in memory. It's intended for
+// tests and local development. Unlike SQLStore, the legal owner is not
+// verified, no revisions are recorded, and the legal owner facets carry only
+// the public ID.
+type MemoryStore struct {
+  mutex    sync.RWMutex
+  products map[string]*Product // by public ID
+  pubIds   map[int64]string
+  lastId   int64
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+  return &MemoryStore{products: make(map[string]*Product), pubIds: make(map[int64]string)}
+}
+
+// memorySorts implement ProductsSorts for the MemoryStore.
+var memorySorts = map[string]func(a, b *Product) bool{
+  ``: nameAsc,
+  `name-asc`: nameAsc,
+  `name-desc`: func(a, b *Product) bool { return nameAsc(b, a) },
+  `relevance`: nameAsc, // see 'sorted'
+}
+
+func nameAsc(a, b *Product) bool {
+  return a.DisplayName.String < b.DisplayName.String || (a.DisplayName.String == b.DisplayName.String && a.Id.Int64 < b.Id.Int64)
+}
+
+func (s *MemoryStore) Get(pubId string, includeArchived bool, ctx context.Context) (*Product, rest.RestError) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  p, ok := s.products[pubId]
+  if !ok || (p.Archived.Bool && !includeArchived) {
+    return nil, rest.NotFoundError(fmt.Sprintf(`Product '%s' not found.`, pubId), nil)
+  }
+  return formatOut(p), nil
+}
+
+func (s *MemoryStore) GetByID(id int64, ctx context.Context) (*Product, rest.RestError) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  pubId, ok := s.pubIds[id]
+  if !ok {
+    return nil, rest.NotFoundError(fmt.Sprintf(`Product '%d' not found.`, id), nil)
+  }
+  return formatOut(s.products[pubId]), nil
+}
+
+func (s *MemoryStore) Create(p *Product, ctx context.Context) (*Product, rest.RestError) {
+  if validationErr := p.Validate(); validationErr != nil {
+    return nil, *validationErr
+  }
+  pubId, err := newPubId()
+  if err != nil {
+    return nil, rest.ServerError("Could not generate product ID.", err)
+  }
+
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.lastId += 1
+  newP := p.Clone()
+  newP.Id = nulls.NewInt64(s.lastId)
+  newP.PubId = nulls.NewString(pubId)
+  newP.Archived = nulls.NewBool(false)
+  s.save(newP, nulls.NewNullInt64())
+  s.pubIds[s.lastId] = pubId
+
+  return formatOut(newP), nil
+}
+
+func (s *MemoryStore) Update(p *Product, ctx context.Context) (*Product, rest.RestError) {
+  if validationErr := p.Validate(); validationErr != nil {
+    return nil, *validationErr
+  }
+
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  current, restErr := s.checkVersion(p.PubId.String, p.LastUpdated)
+  if restErr != nil {
+    return nil, restErr
+  }
+  newP := p.Clone()
+  newP.Id, newP.Archived = current.Id, current.Archived
+  s.save(newP, current.LastUpdated)
+
+  return formatOut(newP), nil
+}
+
+func (s *MemoryStore) Delete(pubId string, ctx context.Context) (*Product, rest.RestError) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  current, ok := s.products[pubId]
+  if !ok || current.Archived.Bool {
+    return nil, rest.NotFoundError(fmt.Sprintf(`Product '%s' not found.`, pubId), nil)
+  }
+  archived := current.Clone()
+  archived.Archived = nulls.NewBool(true)
+  s.save(archived, current.LastUpdated)
+
+  return formatOut(archived), nil
+}
+
+func (s *MemoryStore) List(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*Product, rest.RestError) {
+  matches, words, restErr := s.sorted(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  searchParams.SetTotalPages(int64(len(matches)))
+
+  pageInfo := searchParams.PageInfo
+  start := (pageInfo.PageIndex - 1) * pageInfo.ItemsPerPage
+  end := start + pageInfo.ItemsPerPage
+  if start > len(matches) {
+    start = len(matches)
+  }
+  if end > len(matches) {
+    end = len(matches)
+  }
+
+  products := make([]*Product, 0, end - start)
+  for _, p := range matches[start:end] {
+    products = append(products, formatResult(p, words))
+  }
+  return products, nil
+}
+
+func (s *MemoryStore) ListPage(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, cursor string, ctx context.Context) (*ProductPage, rest.RestError) {
+  key, position, restErr := decodeCursor(cursor, searchParams.Sort)
+  if restErr != nil {
+    return nil, restErr
+  }
+  matches, words, restErr := s.sorted(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  // Gather, walking away from the position, up to one more than a page.
+  limit := pageLimit(searchParams)
+  products := make([]*Product, 0, limit + 1)
+  if position == nil || !position.Backward {
+    for i := 0; i < len(matches) && len(products) <= limit; i++ {
+      if p := matches[i]; position == nil || key.after(p, position) {
+        products = append(products, formatResult(p, words))
+      }
+    }
+  } else {
+    for i := len(matches) - 1; i >= 0 && len(products) <= limit; i-- {
+      p := matches[i]
+      atPosition := key.value(p) == position.Key && p.Id.Int64 == position.Id
+      if !atPosition && !key.after(p, position) {
+        products = append(products, formatResult(p, words))
+      }
+    }
+  }
+  return newProductPage(products, limit, searchParams.Sort, position), nil
+}
+
+func (s *MemoryStore) Facets(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) (*ProductFacets, rest.RestError) {
+  matches, _, restErr := s.sorted(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  facets := &ProductFacets{Ontology: make([]*OntologyFacet, 0), LegalOwner: make([]*LegalOwnerFacet, 0)}
+  for _, p := range matches {
+    facets.count(p)
+  }
+  facets.sortFacets()
+  return facets, nil
+}
+
+func (s *MemoryStore) Suggest(query string, limit int, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*ProductSuggestion, rest.RestError) {
+  candidates, _, restErr := s.sorted(&rest.SearchParams{}, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  matcher := newNameMatcher(query)
+  for _, p := range candidates {
+    matcher.add(p.PubId.String, p.DisplayName.String)
+  }
+  return matcher.best(limit), nil
+}
+
+// sorted retrieves the Products matching the search and filters, in sort
+// order. For a full-text search, the words of the search are also returned.
+func (s *MemoryStore) sorted(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters) ([]*Product, []fullTextWord, rest.RestError) {
+  less, ok := memorySorts[searchParams.Sort]
+  if !ok {
+    return nil, nil, rest.BadRequestError(fmt.Sprintf(`Unknown sort '%s'.`, searchParams.Sort), nil)
+  }
+  mode := SearchSubstring
+  if filters != nil {
+    if restErr := filters.normalize(); restErr != nil {
+      return nil, nil, restErr
+    }
+    mode = filters.SearchMode
+  }
+  // As with SQL, each term must match.
+  var termWords [][]fullTextWord
+  var words []fullTextWord
+  if mode != SearchSubstring {
+    for _, term := range searchParams.Terms {
+      termWords = append(termWords, parseFullTextTerm(term, mode == SearchBoolean))
+      words = append(words, termWords[len(termWords) - 1]...)
+    }
+  }
+  matchesSearch := func(p *Product) bool {
+    if termWords == nil {
+      return matchesTerms(p, searchParams.Terms)
+    }
+    for _, termWord := range termWords {
+      if !matchesFullText(p, termWord) {
+        return false
+      }
+    }
+    return true
+  }
+
+  s.mutex.RLock()
+  matches := make([]*Product, 0)
+  for _, p := range s.products {
+    if (!p.Archived.Bool || includeArchived) && matchesSearch(p) && filters.matches(p) {
+      matches = append(matches, p)
+    }
+  }
+  s.mutex.RUnlock()
+
+  if searchParams.Sort == `relevance` && words != nil {
+    relevance := make(map[*Product]int, len(matches))
+    for _, p := range matches {
+      relevance[p] = fullTextRelevance(p, words)
+    }
+    nameLess := less
+    less = func(a, b *Product) bool {
+      if relevance[a] != relevance[b] {
+        return relevance[a] > relevance[b]
+      }
+      return nameLess(a, b)
+    }
+  }
+  sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+  return matches, words, nil
+}
+
+// checkVersion implements checkProductVersionInTxn. The caller must hold the
+// write lock.
+func (s *MemoryStore) checkVersion(pubId string, lastUpdated nulls.Int64) (*Product, rest.RestError) {
+  if !lastUpdated.Valid {
+    return nil, preconditionRequiredError(fmt.Sprintf(`Update of product '%s' must specify 'lastUpdated'.`, pubId), nil)
+  }
+  current, ok := s.products[pubId]
+  if !ok || current.Archived.Bool {
+    return nil, rest.NotFoundError(fmt.Sprintf(`Product '%s' not found.`, pubId), nil)
+  }
+  if current.LastUpdated != lastUpdated {
+    return nil, conflictError(fmt.Sprintf(`Product '%s' has been modified (last updated %d, expected %d); re-retrieve and try again.`, pubId, current.LastUpdated.Int64, lastUpdated.Int64), nil)
+  }
+  return current, nil
+}
+
+// save stores the Product, normalizing the phone number and advancing
+// 'LastUpdated' past 'previous' so that each save yields a new version. The
+// caller must hold the write lock.
+func (s *MemoryStore) save(p *Product, previous nulls.Int64) {
+  p.SupportPhone = p.storedPhone()
+  lastUpdated := time.Now().Unix()
+  if previous.Valid && lastUpdated <= previous.Int64 {
+    lastUpdated = previous.Int64 + 1
+  }
+  p.LastUpdated = nulls.NewInt64(lastUpdated)
+  p.ChangeDesc = nulls.NewNullString() // write-only
+  s.products[p.PubId.String] = p
+}
+
+// matchesTerms implements ProductsGeneralWhereGenerator; each term must appear
+// in the display name or summary, ignoring case.
+func matchesTerms(p *Product, terms []string) bool {
+  for _, term := range terms {
+    term = strings.ToLower(term)
+    if !strings.Contains(strings.ToLower(p.DisplayName.String), term) && !strings.Contains(strings.ToLower(p.Summary.String), term) {
+      return false
+    }
+  }
+  return true
+}
+
+// formatOut returns a formatted copy of the stored Product, so callers cannot
+// modify the store contents.
+func formatOut(p *Product) *Product {
+  out := p.Clone()
+  out.FormatOut()
+  return out
+}
+
+// formatResult is formatOut for listings, setting the highlighted Snippet for
+// full-text searches.
+func formatResult(p *Product, words []fullTextWord) *Product {
+  out := formatOut(p)
+  if words != nil {
+    out.Snippet = highlightSnippet(out.Summary.String, words)
+  }
+  return out
+}
+
+// newPubId generates a random (version 4) UUID.
+func newPubId() (string, error) {
+  var b [16]byte
+  if _, err := rand.Read(b[:]); err != nil {
+    return ``, err
+  }
+  b[6] = (b[6] & 0x0f) | 0x40
+  b[8] = (b[8] & 0x3f) | 0x80
+  return strings.ToUpper(fmt.Sprintf(`%x-%x-%x-%x-%x`, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])), nil
+}
@@ -0,0 +1,123 @@
+package products_test
+
+import (
+  "context"
+  "net/http"
+  "sync"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+var _ ProductStore = SQLStore{}
+var _ ProductStore = (*MemoryStore)(nil)
+
+func TestMemoryStoreCRUD(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+
+  product, restErr := store.Create(widgetProduct, ctx)
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  assert.NotEqual(t, widgetProduct.PubId, product.PubId, `Expected new public ID.`)
+  assert.True(t, product.Id.Valid, `Expected internal ID.`)
+  assert.True(t, product.LastUpdated.Valid, `Expected 'lastUpdated'.`)
+  assert.Equal(t, `555-555-9999`, product.SupportPhone.String, `Unexpected phone format.`)
+
+  byID, restErr := store.GetByID(product.Id.Int64, ctx)
+  require.NoError(t, restErr, `Unexpected error getting Product by ID.`)
+  assert.Equal(t, product, byID, `Retrieved Product does not match created.`)
+
+  product.SetDisplayName(`Widget 2.0`)
+  product.SetSupportPhone(`555.555.0004`)
+  updated, restErr := store.Update(product, ctx)
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+  assert.Equal(t, `Widget 2.0`, updated.DisplayName.String, `Unexpected display name.`)
+  assert.Equal(t, `555-555-0004`, updated.SupportPhone.String, `Unexpected phone format on update.`)
+  assert.True(t, updated.LastUpdated.Int64 > product.LastUpdated.Int64, `'lastUpdated' not advanced.`)
+
+  _, restErr = store.Update(product, ctx) // stale
+  require.Error(t, restErr, `Unexpected success on stale update.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on stale update.`)
+
+  noVersion := updated.Clone()
+  noVersion.LastUpdated = nulls.NewNullInt64()
+  _, restErr = store.Update(noVersion, ctx)
+  require.Error(t, restErr, `Unexpected success on unconditional update.`)
+  assert.Equal(t, http.StatusPreconditionRequired, restErr.Code(), `Unexpected error code.`)
+
+  invalid := updated.Clone()
+  invalid.SetSupportEmail(`not an email`)
+  _, restErr = store.Update(invalid, ctx)
+  assert.IsType(t, ValidationError{}, restErr, `Expected validation error.`)
+
+  archived, restErr := store.Delete(updated.PubId.String, ctx)
+  require.NoError(t, restErr, `Unexpected error deleting Product.`)
+  assert.True(t, archived.Archived.Bool, `Product not marked archived.`)
+  _, restErr = store.Get(updated.PubId.String, false, ctx)
+  require.Error(t, restErr, `Unexpected retrieval of archived Product.`)
+  assert.Equal(t, http.StatusNotFound, restErr.Code(), `Unexpected error code.`)
+  _, restErr = store.Get(updated.PubId.String, true, ctx)
+  assert.NoError(t, restErr, `Unexpected error retrieving archived Product.`)
+}
+
+func TestMemoryStoreList(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  for _, name := range []string{`Gizmo`, `Blog`, `Widget`} {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    _, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+  }
+
+  searchParams := &rest.SearchParams{Sort: `name-desc`, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 2}}
+  products, restErr := store.List(searchParams, false, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  require.Len(t, products, 2, `Unexpected page size.`)
+  assert.Equal(t, `Widget`, products[0].DisplayName.String, `Unexpected sort.`)
+  assert.Equal(t, `Gizmo`, products[1].DisplayName.String, `Unexpected sort.`)
+  assert.Equal(t, int64(3), searchParams.PageInfo.TotalItemCount, `Unexpected total item count.`)
+
+  searchParams = &rest.SearchParams{Terms: []string{`blog`}, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+  products, restErr = store.List(searchParams, false, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error searching Products.`)
+  require.Len(t, products, 1, `Unexpected number of Products found.`)
+  assert.Equal(t, `Blog`, products[0].DisplayName.String, `Unexpected Product found.`)
+
+  searchParams.Sort = `bad-sort`
+  _, restErr = store.List(searchParams, false, nil, ctx)
+  assert.Error(t, restErr, `Expected error on unknown sort.`)
+}
+
+func TestMemoryStoreConcurrentUpdate(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  product, restErr := store.Create(widgetProduct, ctx)
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+
+  // Of several updates based on the same version, exactly one wins.
+  var wg sync.WaitGroup
+  results := make(chan rest.RestError, 10)
+  for i := 0; i < 10; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      _, restErr := store.Update(product.Clone(), ctx)
+      results <- restErr
+    }()
+  }
+  wg.Wait()
+  close(results)
+
+  succeeded := 0
+  for restErr := range results {
+    if restErr == nil {
+      succeeded += 1
+    }
+  }
+  assert.Equal(t, 1, succeeded, `Unexpected number of successful updates.`)
+}
@@ -0,0 +1,348 @@
+package products
+
+import (
+  "context"
+  "crypto/sha256"
+  "database/sql"
+  "embed"
+  "fmt"
+  "path"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// The migrations for each dialect are in 'migrations/<dialect name>', named
+// '<version>_<name>.up.sql' and '<version>_<name>.down.sql'. As with the mysql
+// client, a 'DELIMITER' line changes the statement delimiter for statements,
+// such as trigger bodies, which themselves contain ';'.
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a versioned change to the products schema.
+type Migration struct {
+  Version  int
+  Name     string
+  Up       string
+  Down     string
+  // Checksum is the hex encoded SHA-256 of 'Up'. The checksum of each applied
+  // migration is recorded so that changes to released migrations are caught.
+  Checksum string
+}
+
+// MigrateOnSetup determines whether SetupDB applies any pending migrations or
+// only verifies that the schema is current.
+var MigrateOnSetup = true
+
+const schemaVersionStatement = `CREATE TABLE IF NOT EXISTS products_schema_version (
+  version INTEGER NOT NULL PRIMARY KEY,
+  name VARCHAR(128) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+const getSchemaVersionsStatement = `SELECT version, checksum FROM products_schema_version ORDER BY version`
+const countSchemaVersionStatement = `SELECT COUNT(*) FROM products_schema_version WHERE version=?`
+const createSchemaVersionStatement = `INSERT INTO products_schema_version (version, name, checksum) VALUES (?,?,?)`
+const deleteSchemaVersionStatement = `DELETE FROM products_schema_version WHERE version=?`
+
+var migrationFileRegexp = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migrations loads the migrations for the dialect, ordered by version.
+func Migrations(dialect *Dialect) ([]*Migration, error) {
+  dir := path.Join(`migrations`, dialect.Name)
+  entries, err := migrationFiles.ReadDir(dir)
+  if err != nil {
+    return nil, fmt.Errorf("%s: read migrations: %v", dialect.Name, err)
+  }
+
+  byVersion := make(map[int]*Migration)
+  for _, entry := range entries {
+    match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+    if match == nil {
+      return nil, fmt.Errorf("%s: unexpected migration file '%s'", dialect.Name, entry.Name())
+    }
+    version, _ := strconv.Atoi(match[1])
+    m, ok := byVersion[version]
+    if !ok {
+      m = &Migration{Version: version, Name: match[2]}
+      byVersion[version] = m
+    } else if m.Name != match[2] {
+      return nil, fmt.Errorf("%s: migration %d named both '%s' and '%s'", dialect.Name, version, m.Name, match[2])
+    }
+    content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+    if err != nil {
+      return nil, fmt.Errorf("%s: read migration '%s': %v", dialect.Name, entry.Name(), err)
+    }
+    if match[3] == `up` {
+      m.Up = string(content)
+      m.Checksum = fmt.Sprintf(`%x`, sha256.Sum256(content))
+    } else {
+      m.Down = string(content)
+    }
+  }
+
+  migrations := make([]*Migration, 0, len(byVersion))
+  for _, m := range byVersion {
+    if m.Up == `` || m.Down == `` {
+      return nil, fmt.Errorf("%s: migration %d requires both 'up' and 'down' files", dialect.Name, m.Version)
+    }
+    migrations = append(migrations, m)
+  }
+  sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+  for i, m := range migrations {
+    if m.Version != i + 1 {
+      return nil, fmt.Errorf("%s: expected migration %d, found %d", dialect.Name, i + 1, m.Version)
+    }
+  }
+  return migrations, nil
+}
+
+// MigrateSchema applies, in order, each migration not yet applied to the
+// database. Each migration is applied in its own transaction, though MySQL
+// commits schema changes immediately. Concurrent callers, including other
+// instances, wait on each other, so each migration is applied once.
+//
+// A database created before migrations were introduced, from the schema in
+// 'data/', is first recorded at the version it reflects; see
+// adoptLegacySchema.
+func MigrateSchema(db *sql.DB, dialect *Dialect, ctx context.Context) error {
+  return withMigrationLock(db, dialect, ctx, func(conn *sql.Conn, migrations []*Migration, applied []*Migration) error {
+    if len(applied) == 0 {
+      adopted, err := adoptLegacySchema(conn, dialect, migrations, ctx)
+      if err != nil {
+        return err
+      }
+      applied = migrations[:adopted]
+    }
+
+    for _, m := range migrations[len(applied):] {
+      up := func(txn *sql.Tx) error { return execMigrationSQL(txn, m.Up, ctx) }
+      if err := applyMigration(conn, dialect, m, up, ctx); err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+// adoptLegacySchema records, without applying them, the leading migrations
+// whose schema objects already exist, per the dialect 'adoptionProbes', and
+// returns the number recorded. A migration whose objects only partly exist can
+// be neither recorded nor applied, and is reported as an error to be resolved
+// by hand.
+func adoptLegacySchema(conn *sql.Conn, dialect *Dialect, migrations []*Migration, ctx context.Context) (int, error) {
+  noop := func(*sql.Tx) error { return nil }
+  for i, probes := range dialect.adoptionProbes {
+    if i >= len(migrations) {
+      return i, nil
+    }
+    found := 0
+    for _, probe := range probes {
+      if _, err := conn.ExecContext(ctx, probe); err == nil {
+        found += 1
+      }
+    }
+    if found == 0 {
+      return i, nil
+    } else if found < len(probes) {
+      return i, fmt.Errorf("%s: existing schema only partly matches migration %d (%s); complete or remove it before migrating", dialect.Name, migrations[i].Version, migrations[i].Name)
+    }
+    if err := applyMigration(conn, dialect, migrations[i], noop, ctx); err != nil {
+      return i, err
+    }
+  }
+  return len(dialect.adoptionProbes), nil
+}
+
+// applyMigration runs 'up' and records the migration in a single transaction.
+// The migration is skipped if it was applied concurrently, which is possible
+// with SQLite.
+func applyMigration(conn *sql.Conn, dialect *Dialect, m *Migration, up func(*sql.Tx) error, ctx context.Context) error {
+  txn, err := conn.BeginTx(ctx, nil)
+  if err != nil {
+    return fmt.Errorf("%s: begin migration %d: %v", dialect.Name, m.Version, err)
+  }
+  defer txn.Rollback() // no-op after commit
+
+  var count int
+  if err := txn.QueryRowContext(ctx, dialect.Rebind(countSchemaVersionStatement), m.Version).Scan(&count); err != nil {
+    return fmt.Errorf("%s: check migration %d: %v", dialect.Name, m.Version, err)
+  } else if count > 0 {
+    return nil
+  }
+  if err := up(txn); err != nil {
+    return fmt.Errorf("%s: apply migration %d (%s): %v", dialect.Name, m.Version, m.Name, err)
+  }
+  if _, err := txn.ExecContext(ctx, dialect.Rebind(createSchemaVersionStatement), m.Version, m.Name, m.Checksum); err != nil {
+    return fmt.Errorf("%s: record migration %d: %v", dialect.Name, m.Version, err)
+  }
+  if err := txn.Commit(); err != nil {
+    return fmt.Errorf("%s: commit migration %d: %v", dialect.Name, m.Version, err)
+  }
+  return nil
+}
+
+const probeSchemaVersionStatement = `SELECT 1 FROM products_schema_version WHERE 1=0`
+
+// VerifySchema checks that every migration has been applied to the database,
+// without changing it. No lock is taken and nothing is written, so read-only
+// credentials suffice.
+func VerifySchema(db *sql.DB, dialect *Dialect, ctx context.Context) error {
+  migrations, err := Migrations(dialect)
+  if err != nil {
+    return err
+  }
+
+  conn, err := db.Conn(ctx)
+  if err != nil {
+    return fmt.Errorf("%s: connect to verify schema: %v", dialect.Name, err)
+  }
+  defer conn.Close()
+
+  // Without the schema version table, no migrations have been applied.
+  applied := []*Migration{}
+  if _, err := conn.ExecContext(ctx, probeSchemaVersionStatement); err == nil {
+    if applied, err = appliedMigrations(conn, dialect, migrations, ctx); err != nil {
+      return err
+    }
+  } else if ctxErr := ctx.Err(); ctxErr != nil {
+    return fmt.Errorf("%s: verify schema: %v", dialect.Name, ctxErr)
+  }
+
+  if pending := len(migrations) - len(applied); pending > 0 {
+    return fmt.Errorf("%s: schema at version %d; %d migration(s) pending", dialect.Name, len(applied), pending)
+  }
+  return nil
+}
+
+// RollbackSchema reverts, newest first, each applied migration after
+// 'version'. Rolling back to version 0 removes the products schema entirely.
+func RollbackSchema(db *sql.DB, dialect *Dialect, version int, ctx context.Context) error {
+  return withMigrationLock(db, dialect, ctx, func(conn *sql.Conn, migrations []*Migration, applied []*Migration) error {
+    for i := len(applied) - 1; i >= 0 && applied[i].Version > version; i-- {
+      m := applied[i]
+      txn, err := conn.BeginTx(ctx, nil)
+      if err != nil {
+        return fmt.Errorf("%s: begin rollback of migration %d: %v", dialect.Name, m.Version, err)
+      }
+      if err := execMigrationSQL(txn, m.Down, ctx); err != nil {
+        txn.Rollback()
+        return fmt.Errorf("%s: roll back migration %d (%s): %v", dialect.Name, m.Version, m.Name, err)
+      }
+      if _, err := txn.ExecContext(ctx, dialect.Rebind(deleteSchemaVersionStatement), m.Version); err != nil {
+        txn.Rollback()
+        return fmt.Errorf("%s: remove migration %d: %v", dialect.Name, m.Version, err)
+      }
+      if err := txn.Commit(); err != nil {
+        return fmt.Errorf("%s: commit rollback of migration %d: %v", dialect.Name, m.Version, err)
+      }
+    }
+    return nil
+  })
+}
+
+// withMigrationLock runs 'f' holding the dialect migration lock. 'f' is given
+// the available migrations and those applied, after verifying the latter
+// match the former.
+func withMigrationLock(db *sql.DB, dialect *Dialect, ctx context.Context, f func(*sql.Conn, []*Migration, []*Migration) error) error {
+  migrations, err := Migrations(dialect)
+  if err != nil {
+    return err
+  }
+
+  // The lock, and so everything done under it, must be on a single connection.
+  conn, err := db.Conn(ctx)
+  if err != nil {
+    return fmt.Errorf("%s: connect for migrations: %v", dialect.Name, err)
+  }
+  defer conn.Close()
+
+  unlock, err := dialect.lockMigrations(conn, ctx)
+  if err != nil {
+    return fmt.Errorf("%s: lock migrations: %v", dialect.Name, err)
+  }
+  defer unlock()
+
+  if _, err := conn.ExecContext(ctx, schemaVersionStatement); err != nil {
+    return fmt.Errorf("%s: create schema version table: %v", dialect.Name, err)
+  }
+  applied, err := appliedMigrations(conn, dialect, migrations, ctx)
+  if err != nil {
+    return err
+  }
+  return f(conn, migrations, applied)
+}
+
+// appliedMigrations retrieves the applied migrations, verifying that each is
+// known and unchanged.
+func appliedMigrations(conn *sql.Conn, dialect *Dialect, migrations []*Migration, ctx context.Context) ([]*Migration, error) {
+  rows, err := conn.QueryContext(ctx, getSchemaVersionsStatement)
+  if err != nil {
+    return nil, fmt.Errorf("%s: retrieve schema versions: %v", dialect.Name, err)
+  }
+  defer rows.Close()
+
+  applied := make([]*Migration, 0, len(migrations))
+  for rows.Next() {
+    var version int
+    var checksum string
+    if err := rows.Scan(&version, &checksum); err != nil {
+      return nil, fmt.Errorf("%s: read schema version: %v", dialect.Name, err)
+    }
+    if version != len(applied) + 1 {
+      return nil, fmt.Errorf("%s: schema version %d recorded without version %d", dialect.Name, version, len(applied) + 1)
+    } else if version > len(migrations) {
+      return nil, fmt.Errorf("%s: schema version %d is newer than the latest known migration (%d)", dialect.Name, version, len(migrations))
+    }
+    m := migrations[version - 1]
+    if checksum != m.Checksum {
+      return nil, fmt.Errorf("%s: migration %d (%s) has changed since it was applied", dialect.Name, version, m.Name)
+    }
+    applied = append(applied, m)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, fmt.Errorf("%s: read schema versions: %v", dialect.Name, err)
+  }
+  return applied, nil
+}
+
+// execMigrationSQL executes each statement in the migration script.
+func execMigrationSQL(txn *sql.Tx, script string, ctx context.Context) error {
+  for _, statement := range splitMigrationSQL(script) {
+    if _, err := txn.ExecContext(ctx, statement); err != nil {
+      return fmt.Errorf("%v\n%s", err, statement)
+    }
+  }
+  return nil
+}
+
+// splitMigrationSQL splits the script into statements. A statement ends with
+// the line ending in the current delimiter, initially ';'.
+func splitMigrationSQL(script string) []string {
+  statements := make([]string, 0)
+  delimiter := `;`
+  var statement strings.Builder
+  for _, line := range strings.Split(script, "\n") {
+    trimmed := strings.TrimSpace(line)
+    if fields := strings.Fields(trimmed); len(fields) == 2 && strings.EqualFold(fields[0], `DELIMITER`) {
+      delimiter = fields[1]
+      continue
+    }
+    if statement.Len() == 0 && (trimmed == `` || strings.HasPrefix(trimmed, `--`)) {
+      continue
+    }
+
+    if strings.HasSuffix(trimmed, delimiter) {
+      statement.WriteString(strings.TrimSuffix(strings.TrimRight(line, " \t\r"), delimiter))
+      statements = append(statements, statement.String())
+      statement.Reset()
+    } else {
+      statement.WriteString(line + "\n")
+    }
+  }
+  if strings.TrimSpace(statement.String()) != `` {
+    statements = append(statements, statement.String())
+  }
+  return statements
+}
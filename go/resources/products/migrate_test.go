@@ -0,0 +1,142 @@
+package products_test
+
+import (
+  "context"
+  "database/sql"
+  "io/ioutil"
+  "path/filepath"
+  "sync"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+  for _, dialect := range []*Dialect{MySQLDialect, PostgresDialect, SQLiteDialect} {
+    migrations, err := Migrations(dialect)
+    require.NoError(t, err, `Unexpected error loading %s migrations.`, dialect.Name)
+    require.NotEmpty(t, migrations, `Expected %s migrations.`, dialect.Name)
+    assert.Equal(t, `initial`, migrations[0].Name, `Unexpected first %s migration.`, dialect.Name)
+    assert.Len(t, migrations[0].Checksum, 64, `Unexpected %s checksum.`, dialect.Name)
+  }
+}
+
+func countSchemaVersions(t *testing.T, db *sql.DB) int {
+  var count int
+  require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM products_schema_version`).Scan(&count), `Unexpected error counting schema versions.`)
+  return count
+}
+
+func TestMigrateSchema(t *testing.T) {
+  ctx := context.Background()
+  migrations, err := Migrations(SQLiteDialect)
+  require.NoError(t, err, `Unexpected error loading migrations.`)
+  db, err := OpenSQLite(filepath.Join(t.TempDir(), `products.db`))
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+
+  assert.Equal(t, len(migrations), countSchemaVersions(t, db), `Unexpected number of applied migrations.`)
+  assert.NoError(t, VerifySchema(db, SQLiteDialect, ctx), `Unexpected error verifying schema.`)
+  assert.NoError(t, MigrateSchema(db, SQLiteDialect, ctx), `Unexpected error re-applying migrations.`)
+
+  require.NoError(t, RollbackSchema(db, SQLiteDialect, 0, ctx), `Unexpected error rolling back.`)
+  assert.Equal(t, 0, countSchemaVersions(t, db), `Migrations still recorded after rollback.`)
+  _, err = db.Exec(`SELECT 1 FROM products`)
+  assert.Error(t, err, `Expected 'products' to be dropped.`)
+  assert.Error(t, VerifySchema(db, SQLiteDialect, ctx), `Expected pending migrations.`)
+
+  require.NoError(t, MigrateSchema(db, SQLiteDialect, ctx), `Unexpected error re-applying migrations.`)
+  _, err = db.Exec(`UPDATE products_schema_version SET checksum='changed' WHERE version=1`)
+  require.NoError(t, err, `Unexpected error changing checksum.`)
+  assert.Error(t, VerifySchema(db, SQLiteDialect, ctx), `Expected error on changed migration.`)
+  assert.Error(t, MigrateSchema(db, SQLiteDialect, ctx), `Expected error on changed migration.`)
+
+  _, err = db.Exec(`UPDATE products_schema_version SET checksum=? WHERE version=1`, migrations[0].Checksum)
+  require.NoError(t, err, `Unexpected error restoring checksum.`)
+  _, err = db.Exec(`INSERT INTO products_schema_version (version, name, checksum) VALUES (?, 'future', 'x')`, len(migrations) + 1)
+  require.NoError(t, err, `Unexpected error recording future migration.`)
+  assert.Error(t, VerifySchema(db, SQLiteDialect, ctx), `Expected error on unknown migration.`)
+}
+
+func TestVerifySchemaReadOnly(t *testing.T) {
+  path := filepath.Join(t.TempDir(), `products.db`)
+  require.NoError(t, ioutil.WriteFile(path, nil, 0644), `Unexpected error creating database file.`) // an empty database
+  db, err := sql.Open(`sqlite`, `file:` + path + `?mode=ro`)
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+
+  err = VerifySchema(db, SQLiteDialect, context.Background())
+  require.Error(t, err, `Unexpected success verifying empty schema.`)
+  assert.Contains(t, err.Error(), `schema at version 0`, `Unexpected error.`)
+}
+
+// legacySchema stands in for a SQLite database created before migrations were
+// introduced, less the 'archived' column.
+const legacySchema = `CREATE TABLE entities (id INTEGER PRIMARY KEY AUTOINCREMENT, pub_id VARCHAR(36) NOT NULL UNIQUE, last_updated INTEGER NOT NULL DEFAULT 0);
+CREATE TABLE users (id INTEGER PRIMARY KEY, auth_id VARCHAR(255), legal_id VARCHAR(64), legal_id_type VARCHAR(16), active BOOLEAN NOT NULL DEFAULT 0);
+CREATE TABLE products (id INTEGER PRIMARY KEY, legal_owner INTEGER NOT NULL, display_name VARCHAR(128) NOT NULL, summary VARCHAR(512) NOT NULL, support_phone VARCHAR(12), support_email VARCHAR(255) NOT NULL, homepage VARCHAR(255), logo_url VARCHAR(255), repo_url VARCHAR(255), issues_url VARCHAR(255), ontology VARCHAR(32));
+CREATE TABLE product_revisions (product INTEGER NOT NULL, revision INTEGER NOT NULL, snapshot TEXT NOT NULL, change_desc VARCHAR(1024), actor VARCHAR(128), created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (product, revision));`
+
+func TestMigrateLegacySchema(t *testing.T) {
+  ctx := context.Background()
+  db, err := sql.Open(`sqlite`, `file:` + filepath.Join(t.TempDir(), `products.db`))
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+  _, err = db.Exec(legacySchema)
+  require.NoError(t, err, `Unexpected error creating legacy schema.`)
+
+  // The schema can be neither recorded at version 1 nor migrated.
+  err = MigrateSchema(db, SQLiteDialect, ctx)
+  require.Error(t, err, `Unexpected success migrating incomplete legacy schema.`)
+  assert.Contains(t, err.Error(), `only partly matches migration 1`, `Unexpected error.`)
+  assert.Equal(t, 0, countSchemaVersions(t, db), `Unexpected migrations recorded.`)
+
+  _, err = db.Exec(`ALTER TABLE products ADD COLUMN archived BOOLEAN NOT NULL DEFAULT 0`)
+  require.NoError(t, err, `Unexpected error completing legacy schema.`)
+  require.NoError(t, MigrateSchema(db, SQLiteDialect, ctx), `Unexpected error migrating legacy schema.`)
+  assert.NoError(t, VerifySchema(db, SQLiteDialect, ctx), `Unexpected error verifying schema.`)
+  _, err = db.Exec(`SELECT 1 FROM products_fts WHERE 1=0`)
+  assert.NoError(t, err, `Expected later migrations to be applied.`)
+}
+
+func TestMySQLMigrationsFromBaseline(t *testing.T) {
+  migrations, err := Migrations(MySQLDialect)
+  require.NoError(t, err, `Unexpected error loading migrations.`)
+  require.True(t, len(migrations) >= 2, `Expected at least two migrations.`)
+  // Migration 1 is the original schema, without later additions.
+  assert.NotContains(t, migrations[0].Up, `archived`, `Unexpected 'archived' column in migration 1.`)
+  assert.NotContains(t, migrations[0].Up, `product_revisions`, `Unexpected revisions in migration 1.`)
+  assert.Contains(t, migrations[1].Up, "ADD COLUMN `archived`", `Expected 'archived' column in migration 2.`)
+  assert.Contains(t, migrations[1].Up, "CREATE TABLE `product_revisions`", `Expected revisions in migration 2.`)
+}
+
+func TestMigrateSchemaConcurrently(t *testing.T) {
+  path := filepath.Join(t.TempDir(), `products.db`)
+  db, err := OpenSQLite(path)
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+  require.NoError(t, RollbackSchema(db, SQLiteDialect, 0, context.Background()), `Unexpected error rolling back.`)
+
+  // Separate pools stand in for separate instances.
+  var wg sync.WaitGroup
+  errs := make(chan error, 4)
+  for i := 0; i < 4; i++ {
+    instanceDB, err := sql.Open(`sqlite`, `file:` + path + `?_pragma=busy_timeout(5000)&_txlock=immediate`)
+    require.NoError(t, err, `Unexpected error opening SQLite database.`)
+    defer instanceDB.Close()
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      errs <- MigrateSchema(instanceDB, SQLiteDialect, context.Background())
+    }()
+  }
+  wg.Wait()
+  close(errs)
+
+  for err := range errs {
+    assert.NoError(t, err, `Unexpected error migrating concurrently.`)
+  }
+  assert.NoError(t, VerifySchema(db, SQLiteDialect, context.Background()), `Unexpected error verifying schema.`)
+}
@@ -0,0 +1,2 @@
+DROP TRIGGER `products_phone_format`;
+DROP TABLE `products`;
@@ -0,0 +1,4 @@
+DROP TRIGGER `product_revisions_no_delete`;
+DROP TRIGGER `product_revisions_no_update`;
+DROP TABLE `product_revisions`;
+ALTER TABLE `products` DROP COLUMN `archived`;
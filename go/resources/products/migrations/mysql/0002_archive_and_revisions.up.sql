@@ -0,0 +1,27 @@
+ALTER TABLE `products` ADD COLUMN `archived` BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE `product_revisions` (
+  `product` INT(10) NOT NULL,
+  `revision` INT(10) NOT NULL,
+-- JSON encoded Product as of the revision
+  `snapshot` TEXT NOT NULL,
+  `change_desc` VARCHAR(1024),
+  `actor` VARCHAR(128),
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT `product_revisions_key` PRIMARY KEY ( `product`, `revision` ),
+  CONSTRAINT `product_revisions_ref_products` FOREIGN KEY ( `product` ) REFERENCES `products` ( `id` )
+);
+-- revisions are an audit trail and must never change
+DELIMITER //
+CREATE TRIGGER `product_revisions_no_update`
+  BEFORE UPDATE ON product_revisions FOR EACH ROW
+    BEGIN
+      SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT='product revisions are immutable';
+    END;//
+CREATE TRIGGER `product_revisions_no_delete`
+  BEFORE DELETE ON product_revisions FOR EACH ROW
+    BEGIN
+      SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT='product revisions are immutable';
+    END;//
+DELIMITER ;
@@ -0,0 +1,3 @@
+-- supports the 'natural' and 'boolean' search modes and the 'relevance' sort;
+-- the columns must match the MATCH in 'mysqlFullText'
+ALTER TABLE `products` ADD FULLTEXT INDEX `products_fulltext` (`display_name`, `summary`);
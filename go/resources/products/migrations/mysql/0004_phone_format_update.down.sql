@@ -0,0 +1 @@
+DROP TRIGGER `products_phone_format_update`;
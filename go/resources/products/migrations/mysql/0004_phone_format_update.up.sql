@@ -0,0 +1,8 @@
+-- 'products_phone_format' only reformats inserted phone numbers
+DELIMITER //
+CREATE TRIGGER `products_phone_format_update`
+  BEFORE UPDATE ON products FOR EACH ROW
+    BEGIN
+      SET new.support_phone=(SELECT NUMERIC_ONLY(new.support_phone));
+    END;//
+DELIMITER ;
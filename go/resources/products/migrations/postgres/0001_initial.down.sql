@@ -0,0 +1,5 @@
+DROP TABLE product_revisions;
+DROP FUNCTION product_revisions_immutable();
+DROP TABLE products;
+DROP FUNCTION products_phone_format();
+DROP TYPE product_ontology;
@@ -0,0 +1,65 @@
+CREATE TYPE product_ontology AS ENUM ('TANGIBLE GOOD', 'DIGITAL GOOD', 'SOFTWARE SERVICE', 'CONSULTING SERVICE', 'PHYSICAL SERVICE');
+
+CREATE TABLE products (
+  id INTEGER,
+  legal_owner INTEGER NOT NULL,
+  display_name VARCHAR(128) NOT NULL,
+  summary VARCHAR(512) NOT NULL,
+-- see ../docs/Relational-Schemas.md#reformatting-data-via-a-trigger
+  support_phone VARCHAR(12),
+  support_email VARCHAR(255) NOT NULL,
+  homepage VARCHAR(255),
+  logo_url VARCHAR(255),
+  repo_url VARCHAR(255),
+  issues_url VARCHAR(255),
+  ontology product_ontology,
+  archived BOOLEAN NOT NULL DEFAULT FALSE,
+
+  CONSTRAINT products_key PRIMARY KEY ( id ),
+  CONSTRAINT products_ref_entities FOREIGN KEY ( id ) REFERENCES entities ( id ),
+  CONSTRAINT products_ref_users FOREIGN KEY ( legal_owner ) REFERENCES users ( id )
+);
+
+-- equivalent to the MySQL 'NUMERIC_ONLY'
+DELIMITER //
+CREATE FUNCTION products_phone_format() RETURNS trigger AS $$
+  BEGIN
+    NEW.support_phone := regexp_replace(NEW.support_phone, '[^0-9]', '', 'g');
+    RETURN NEW;
+  END;
+$$ LANGUAGE plpgsql;//
+DELIMITER ;
+
+CREATE TRIGGER products_phone_format
+  BEFORE INSERT ON products FOR EACH ROW
+    EXECUTE PROCEDURE products_phone_format();
+
+CREATE TABLE product_revisions (
+  product INTEGER NOT NULL,
+  revision INTEGER NOT NULL,
+-- JSON encoded Product as of the revision
+  snapshot TEXT NOT NULL,
+  change_desc VARCHAR(1024),
+  actor VARCHAR(128),
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT product_revisions_key PRIMARY KEY ( product, revision ),
+  CONSTRAINT product_revisions_ref_products FOREIGN KEY ( product ) REFERENCES products ( id )
+);
+
+-- revisions are an audit trail and must never change
+DELIMITER //
+CREATE FUNCTION product_revisions_immutable() RETURNS trigger AS $$
+  BEGIN
+    RAISE EXCEPTION 'product revisions are immutable';
+  END;
+$$ LANGUAGE plpgsql;//
+DELIMITER ;
+
+CREATE TRIGGER product_revisions_no_update
+  BEFORE UPDATE ON product_revisions FOR EACH ROW
+    EXECUTE PROCEDURE product_revisions_immutable();
+
+CREATE TRIGGER product_revisions_no_delete
+  BEFORE DELETE ON product_revisions FOR EACH ROW
+    EXECUTE PROCEDURE product_revisions_immutable();
@@ -0,0 +1,3 @@
+-- supports the 'natural' and 'boolean' search modes and the 'relevance' sort;
+-- the expression must match the vector in 'postgresFullText'
+CREATE INDEX products_fulltext ON products USING GIN (to_tsvector('english', display_name || ' ' || summary));
@@ -0,0 +1,4 @@
+-- 'products_phone_format' only reformats inserted phone numbers
+CREATE TRIGGER products_phone_format_update
+  BEFORE UPDATE OF support_phone ON products FOR EACH ROW
+    EXECUTE PROCEDURE products_phone_format();
@@ -0,0 +1,4 @@
+DROP TABLE product_revisions;
+DROP TABLE products;
+DROP TABLE users;
+DROP TABLE entities;
@@ -0,0 +1,68 @@
+-- SQLite databases are self-contained, so include minimal stand-ins for the
+-- catalyst-core-api 'entities' and 'users' tables. 'last_updated' is in
+-- seconds since the epoch and is advanced by the products package on each
+-- change.
+CREATE TABLE entities (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  pub_id VARCHAR(36) NOT NULL UNIQUE,
+  last_updated INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER))
+);
+
+CREATE TABLE users (
+  id INTEGER PRIMARY KEY REFERENCES entities (id),
+  auth_id VARCHAR(255),
+  legal_id VARCHAR(64),
+  legal_id_type VARCHAR(16),
+  active BOOLEAN NOT NULL DEFAULT 0
+);
+
+-- SQLite has no ENUM, so 'ontology' is limited with a CHECK.
+CREATE TABLE products (
+  id INTEGER PRIMARY KEY REFERENCES entities (id),
+  legal_owner INTEGER NOT NULL REFERENCES users (id),
+  display_name VARCHAR(128) NOT NULL,
+  summary VARCHAR(512) NOT NULL,
+  support_phone VARCHAR(12),
+  support_email VARCHAR(255) NOT NULL,
+  homepage VARCHAR(255),
+  logo_url VARCHAR(255),
+  repo_url VARCHAR(255),
+  issues_url VARCHAR(255),
+  ontology VARCHAR(32) CHECK (ontology IN ('TANGIBLE GOOD', 'DIGITAL GOOD', 'SOFTWARE SERVICE', 'CONSULTING SERVICE', 'PHYSICAL SERVICE')),
+  archived BOOLEAN NOT NULL DEFAULT 0
+);
+
+-- SQLite has no NUMERIC_ONLY, so we strip the usual phone punctuation.
+DELIMITER //
+CREATE TRIGGER products_phone_format
+  AFTER INSERT ON products FOR EACH ROW WHEN new.support_phone IS NOT NULL
+    BEGIN
+      UPDATE products SET support_phone=REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(new.support_phone, '-', ''), ' ', ''), '(', ''), ')', ''), '.', ''), '+', '') WHERE id=new.id;
+    END;//
+DELIMITER ;
+
+CREATE TABLE product_revisions (
+  product INTEGER NOT NULL REFERENCES products (id),
+  revision INTEGER NOT NULL,
+-- JSON encoded Product as of the revision
+  snapshot TEXT NOT NULL,
+  change_desc VARCHAR(1024),
+  actor VARCHAR(128),
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (product, revision)
+);
+
+-- revisions are an audit trail and must never change
+DELIMITER //
+CREATE TRIGGER product_revisions_no_update
+  BEFORE UPDATE ON product_revisions FOR EACH ROW
+    BEGIN
+      SELECT RAISE(ABORT, 'product revisions are immutable');
+    END;//
+CREATE TRIGGER product_revisions_no_delete
+  BEFORE DELETE ON product_revisions FOR EACH ROW
+    BEGIN
+      SELECT RAISE(ABORT, 'product revisions are immutable');
+    END;//
+DELIMITER ;
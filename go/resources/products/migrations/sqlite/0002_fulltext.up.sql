@@ -0,0 +1,24 @@
+-- supports the 'natural' and 'boolean' search modes and the 'relevance' sort;
+-- the FTS5 index is kept in sync with 'products' by the triggers
+CREATE VIRTUAL TABLE products_fts USING fts5(display_name, summary, content='products', content_rowid='id');
+
+DELIMITER //
+CREATE TRIGGER products_fts_insert
+  AFTER INSERT ON products FOR EACH ROW
+    BEGIN
+      INSERT INTO products_fts (rowid, display_name, summary) VALUES (new.id, new.display_name, new.summary);
+    END;//
+CREATE TRIGGER products_fts_update
+  AFTER UPDATE OF display_name, summary ON products FOR EACH ROW
+    BEGIN
+      INSERT INTO products_fts (products_fts, rowid, display_name, summary) VALUES ('delete', old.id, old.display_name, old.summary);
+      INSERT INTO products_fts (rowid, display_name, summary) VALUES (new.id, new.display_name, new.summary);
+    END;//
+CREATE TRIGGER products_fts_delete
+  AFTER DELETE ON products FOR EACH ROW
+    BEGIN
+      INSERT INTO products_fts (products_fts, rowid, display_name, summary) VALUES ('delete', old.id, old.display_name, old.summary);
+    END;//
+DELIMITER ;
+
+INSERT INTO products_fts (products_fts) VALUES ('rebuild');
@@ -0,0 +1,9 @@
+-- 'products_phone_format' only reformats inserted phone numbers; SQLite
+-- triggers are not recursive by default, so the UPDATE does not re-trigger
+DELIMITER //
+CREATE TRIGGER products_phone_format_update
+  AFTER UPDATE OF support_phone ON products FOR EACH ROW WHEN new.support_phone IS NOT NULL
+    BEGIN
+      UPDATE products SET support_phone=REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(new.support_phone, '-', ''), ' ', ''), '(', ''), ')', ''), '.', ''), '+', '') WHERE id=new.id;
+    END;//
+DELIMITER ;
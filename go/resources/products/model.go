@@ -9,6 +9,9 @@ import (
 
 var phoneOutFormatter *regexp.Regexp = regexp.MustCompile(`^(\d{3})(\d{3})(\d{4})$`)
 
+// Mirrors the 'products_phone_format' triggers.
+var nonNumeric = regexp.MustCompile(`[^0-9]`)
+
 // On summary, we don't include address. Note leaving it empty and using
 // 'omitempty' on the Product struct won't work because then Products without an address
 // will appear 'incomplete' in the front-end model and never resolve.
@@ -24,12 +27,68 @@ type Product struct {
   RepoURL         nulls.String `json:"repoURL"`
   IssuesURL       nulls.String `json:"issuesURL"`
   Ontology        nulls.String `json:"ontology"`
+  // Archived is read-only; use DeleteProduct and RestoreProduct to change it.
+  Archived        nulls.Bool   `json:"archived"`
+  // ChangeDesc is write-only; it describes the change being made and is
+  // recorded with the resulting ProductRevision.
+  ChangeDesc      nulls.String `json:"changeDesc"`
+  // Snippet is read-only; for full-text searches, it holds the highlighted
+  // summary text matching the search. See SearchNatural.
+  Snippet         string       `json:"snippet,omitempty"`
+}
+
+// ProductOntologies lists the allowed Product 'Ontology' values.
+var ProductOntologies = []string{`TANGIBLE GOOD`, `DIGITAL GOOD`, `SOFTWARE SERVICE`, `CONSULTING SERVICE`, `PHYSICAL SERVICE`}
+
+// productField describes a writable Product field: its JSON name, the
+// corresponding 'products' column, and an accessor for the field value.
+type productField struct {
+  jsonName string
+  column   string
+  value    func(p *Product) *nulls.String
+}
+
+// productFields lists the writable Product fields in canonical order. Note
+// that 'legal_owner' holds the internal ID of the legal owner and must be
+// resolved from the public 'legalOwnerPubID'.
+var productFields = []productField{
+  {`legalOwnerPubID`, `legal_owner`, func(p *Product) *nulls.String { return &p.LegalOwnerPubID }},
+  {`displayName`, `display_name`, func(p *Product) *nulls.String { return &p.DisplayName }},
+  {`summary`, `summary`, func(p *Product) *nulls.String { return &p.Summary }},
+  {`supportEmail`, `support_email`, func(p *Product) *nulls.String { return &p.SupportEmail }},
+  {`supportPhone`, `support_phone`, func(p *Product) *nulls.String { return &p.SupportPhone }},
+  {`homepage`, `homepage`, func(p *Product) *nulls.String { return &p.Homepage }},
+  {`logoURL`, `logo_url`, func(p *Product) *nulls.String { return &p.LogoURL }},
+  {`repoURL`, `repo_url`, func(p *Product) *nulls.String { return &p.RepoURL }},
+  {`issuesURL`, `issues_url`, func(p *Product) *nulls.String { return &p.IssuesURL }},
+  {`ontology`, `ontology`, func(p *Product) *nulls.String { return &p.Ontology }},
+}
+
+// findProductField retrieves the writable field description by JSON name.
+func findProductField(jsonName string) (productField, bool) {
+  for _, field := range productFields {
+    if field.jsonName == jsonName {
+      return field, true
+    }
+  }
+  return productField{}, false
 }
 
 func (p *Product) FormatOut() {
   p.SupportPhone.String = phoneOutFormatter.ReplaceAllString(p.SupportPhone.String, `$1-$2-$3`)
 }
 
+// storedPhone is the support phone reduced to its digits, as it is stored. The
+// phone is reduced before writing, rather than left to the triggers, because
+// punctuated input may not fit the column.
+func (p *Product) storedPhone() nulls.String {
+  phone := p.SupportPhone
+  if phone.Valid {
+    phone.String = nonNumeric.ReplaceAllString(phone.String, ``)
+  }
+  return phone
+}
+
 func (p *Product) SetLegalOwnerPubID(val string) {
   p.LegalOwnerPubID = nulls.NewString(val)
 }
@@ -70,6 +129,10 @@ func (p *Product) SetOntology(val string) {
   p.Ontology = nulls.NewString(val)
 }
 
+func (p *Product) SetChangeDesc(val string) {
+  p.ChangeDesc = nulls.NewString(val)
+}
+
 func (p *Product) Clone() *Product {
   return &Product{
     *p.Entity.Clone(),
@@ -83,5 +146,8 @@ func (p *Product) Clone() *Product {
     p.RepoURL,
     p.IssuesURL,
     p.Ontology,
+    p.Archived,
+    p.ChangeDesc,
+    p.Snippet,
   }
 }
@@ -28,6 +28,9 @@ var widgetProduct = &Product{
   nulls.NewString(`https://foo.com/products/widget/repo`),
   nulls.NewString(`https://foo.com/products/widget/issues`),
   nulls.NewString(`TANGIBLE GOOD`),
+  nulls.NewBool(false),
+  nulls.NewString(`Initial release.`),
+  ``,
 }
 
 func TestProductClone(t *testing.T) {
@@ -46,6 +49,9 @@ func TestProductClone(t *testing.T) {
   clone.SetRepoURL(`https://bar.com/widget_repo`)
   clone.SetIssuesURL(`https://bar.com/issues`)
   clone.SetOntology(`DIGITAL GOOD`)
+  clone.Archived = nulls.NewBool(true)
+  clone.SetChangeDesc(`Rebranded.`)
+  clone.Snippet = `A new <mark>summary</mark>.`
 
   oReflection := reflect.ValueOf(widgetProduct).Elem()
   cReflection := reflect.ValueOf(clone).Elem()
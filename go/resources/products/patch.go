@@ -0,0 +1,253 @@
+package products
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "reflect"
+  "strings"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+const MergePatchContentType = `application/merge-patch+json`
+const JSONPatchContentType = `application/json-patch+json`
+
+// Read-only fields which may appear in a patch as preconditions. A merge patch
+// may set 'lastUpdated' (or a matching 'pubId') and a JSON Patch may 'test'
+// them, but neither may be otherwise modified.
+const lastUpdatedJSONName = `lastUpdated`
+const pubIdJSONName = `pubId`
+// 'changeDesc' may be set by a patch, but describes rather than constitutes a
+// change, so it is never reported as touched.
+const changeDescJSONName = `changeDesc`
+
+// ApplyMergePatch applies an RFC 7396 JSON Merge Patch to a copy of the
+// Product. The patched copy is returned along with the JSON names of the
+// fields touched by the patch. Since all writable Product fields are simple
+// values, the patch must be a flat JSON object.
+func ApplyMergePatch(p *Product, patch []byte) (*Product, []string, rest.RestError) {
+  var patchDoc map[string]interface{}
+  if err := json.Unmarshal(patch, &patchDoc); err != nil || patchDoc == nil {
+    return nil, nil, rest.BadRequestError(`Merge patch must be a JSON object.`, err)
+  }
+
+  doc, restErr := productDoc(p)
+  if restErr != nil {
+    return nil, nil, restErr
+  }
+
+  touchedSet := make(map[string]bool)
+  for name, value := range patchDoc {
+    if name == lastUpdatedJSONName || name == pubIdJSONName {
+      doc[name] = value
+      continue
+    }
+    if name == changeDescJSONName {
+      if restErr := checkPatchString(name, value); restErr != nil {
+        return nil, nil, restErr
+      }
+      doc[name] = value
+      continue
+    }
+    if restErr := checkPatchValue(name, value); restErr != nil {
+      return nil, nil, restErr
+    }
+    doc[name] = value
+    touchedSet[name] = true
+  }
+
+  patched, restErr := productFromDoc(p, doc)
+  if restErr != nil {
+    return nil, nil, restErr
+  }
+  if patched.PubId != p.PubId {
+    return nil, nil, rest.UnprocessableEntityError(`Patch may not change 'pubId'.`, nil)
+  }
+
+  return patched, touchedFields(touchedSet), nil
+}
+
+// mergePatchVersioned reports whether the merge patch sets 'lastUpdated',
+// identifying the version of the Product it applies to.
+func mergePatchVersioned(patch []byte) bool {
+  var patchDoc map[string]json.RawMessage
+  if err := json.Unmarshal(patch, &patchDoc); err != nil {
+    return false
+  }
+  _, ok := patchDoc[lastUpdatedJSONName]
+  return ok
+}
+
+// jsonPatchOp is a single RFC 6902 JSON Patch operation.
+type jsonPatchOp struct {
+  Op    string          `json:"op"`
+  Path  string          `json:"path"`
+  From  string          `json:"from"`
+  Value json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to a copy of the Product. The
+// patched copy is returned along with the JSON names of the fields touched by
+// the patch. Paths must reference top level Product fields. A failed 'test'
+// operation results in a 409 (conflict) error.
+func ApplyJSONPatch(p *Product, patch []byte) (*Product, []string, rest.RestError) {
+  var ops []jsonPatchOp
+  if err := json.Unmarshal(patch, &ops); err != nil {
+    return nil, nil, rest.BadRequestError(`JSON Patch must be an array of operations.`, err)
+  }
+
+  doc, restErr := productDoc(p)
+  if restErr != nil {
+    return nil, nil, restErr
+  }
+
+  touchedSet := make(map[string]bool)
+  for i, op := range ops {
+    path, restErr := patchPathName(op.Path)
+    if restErr != nil {
+      return nil, nil, restErr
+    }
+
+    var value interface{}
+    if op.Op == `add` || op.Op == `replace` || op.Op == `test` {
+      if op.Value == nil {
+        return nil, nil, rest.BadRequestError(fmt.Sprintf(`Patch operation %d ('%s') requires a 'value'.`, i, op.Op), nil)
+      }
+      if err := json.Unmarshal(op.Value, &value); err != nil {
+        return nil, nil, rest.BadRequestError(fmt.Sprintf(`Could not decode value of patch operation %d.`, i), err)
+      }
+    }
+
+    switch op.Op {
+    case `test`:
+      current, ok := doc[path]
+      if !ok {
+        return nil, nil, rest.UnprocessableEntityError(fmt.Sprintf(`Unknown product field '%s'.`, path), nil)
+      }
+      if !reflect.DeepEqual(current, value) {
+        return nil, nil, conflictError(fmt.Sprintf(`Patch test failed for '%s'.`, path), nil)
+      }
+      continue
+    case `add`, `replace`:
+      if path == changeDescJSONName {
+        if restErr := checkPatchString(path, value); restErr != nil {
+          return nil, nil, restErr
+        }
+        doc[path] = value
+        continue
+      }
+    case `remove`:
+      value = nil
+    case `move`, `copy`:
+      from, restErr := patchPathName(op.From)
+      if restErr != nil {
+        return nil, nil, restErr
+      }
+      if from != path && op.Op == `move` {
+        if restErr := checkPatchValue(from, nil); restErr != nil {
+          return nil, nil, restErr
+        }
+        touchedSet[from] = true
+      }
+      value = doc[from]
+      if op.Op == `move` {
+        doc[from] = nil
+      }
+    default:
+      return nil, nil, rest.BadRequestError(fmt.Sprintf(`Unknown patch operation '%s'.`, op.Op), nil)
+    }
+
+    if restErr := checkPatchValue(path, value); restErr != nil {
+      return nil, nil, restErr
+    }
+    doc[path] = value
+    touchedSet[path] = true
+  }
+
+  patched, restErr := productFromDoc(p, doc)
+  if restErr != nil {
+    return nil, nil, restErr
+  }
+
+  return patched, touchedFields(touchedSet), nil
+}
+
+// jsonPatchVersioned reports whether the JSON Patch tests 'lastUpdated',
+// identifying the version of the Product it applies to.
+func jsonPatchVersioned(patch []byte) bool {
+  var ops []jsonPatchOp
+  if err := json.Unmarshal(patch, &ops); err != nil {
+    return false
+  }
+  for _, op := range ops {
+    if op.Op == `test` && op.Path == `/` + lastUpdatedJSONName {
+      return true
+    }
+  }
+  return false
+}
+
+// touchedFields orders the touched field names per productFields.
+func touchedFields(touchedSet map[string]bool) []string {
+  touched := make([]string, 0, len(touchedSet))
+  for _, field := range productFields {
+    if touchedSet[field.jsonName] {
+      touched = append(touched, field.jsonName)
+    }
+  }
+  return touched
+}
+
+// patchPathName extracts the field name from a JSON Pointer (RFC 6901)
+// referencing a top level field.
+func patchPathName(path string) (string, rest.RestError) {
+  if !strings.HasPrefix(path, `/`) || strings.Count(path, `/`) != 1 {
+    return ``, rest.UnprocessableEntityError(fmt.Sprintf(`Patch path '%s' must reference a top level product field.`, path), nil)
+  }
+  return strings.NewReplacer(`~1`, `/`, `~0`, `~`).Replace(path[1:]), nil
+}
+
+// checkPatchValue verifies that the named field is writable and the value is
+// either null or a string.
+func checkPatchValue(name string, value interface{}) rest.RestError {
+  if _, ok := findProductField(name); !ok {
+    return rest.UnprocessableEntityError(fmt.Sprintf(`Product field '%s' is unknown or read-only.`, name), nil)
+  }
+  return checkPatchString(name, value)
+}
+
+// checkPatchString verifies the value is either null or a string.
+func checkPatchString(name string, value interface{}) rest.RestError {
+  if _, ok := value.(string); value != nil && !ok {
+    return rest.UnprocessableEntityError(fmt.Sprintf(`Product field '%s' must be a string or null.`, name), nil)
+  }
+  return nil
+}
+
+// productDoc converts a Product to a generic JSON document.
+func productDoc(p *Product) (map[string]interface{}, rest.RestError) {
+  var doc map[string]interface{}
+  if data, err := json.Marshal(p); err != nil {
+    return nil, rest.ServerError(`Could not encode product for patching.`, err)
+  } else if err := json.Unmarshal(data, &doc); err != nil {
+    return nil, rest.ServerError(`Could not decode product for patching.`, err)
+  }
+  return doc, nil
+}
+
+// productFromDoc decodes a generic JSON document onto a copy of the original
+// Product. The copy retains any fields, such as the internal ID, which are not
+// represented in JSON.
+func productFromDoc(orig *Product, doc map[string]interface{}) (*Product, rest.RestError) {
+  data, err := json.Marshal(doc)
+  if err != nil {
+    return nil, rest.ServerError(`Could not encode patched product.`, err)
+  }
+  patched := orig.Clone()
+  decoder := json.NewDecoder(bytes.NewReader(data))
+  if err := decoder.Decode(patched); err != nil {
+    return nil, rest.UnprocessableEntityError(`Could not decode patched product.`, err)
+  }
+  return patched, nil
+}
@@ -0,0 +1,59 @@
+package products_test
+
+import (
+  "net/http"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+  patched, fields, restErr := ApplyMergePatch(widgetProduct, []byte(`{"supportPhone": "555-555-1234", "repoURL": null}`))
+  require.NoError(t, restErr, `Unexpected error applying merge patch.`)
+  assert.Equal(t, []string{`supportPhone`, `repoURL`}, fields, `Unexpected touched fields.`)
+  assert.Equal(t, `555-555-1234`, patched.SupportPhone.String, `Unexpected phone.`)
+  assert.False(t, patched.RepoURL.Valid, `Repo URL not nulled.`)
+  assert.Equal(t, widgetProduct.DisplayName, patched.DisplayName, `Untouched field changed.`)
+  assert.Equal(t, widgetProduct.Id, patched.Id, `Internal ID not retained.`)
+  assert.Equal(t, `https://foo.com/products/widget/repo`, widgetProduct.RepoURL.String, `Original product modified.`)
+}
+
+func TestApplyMergePatchErrors(t *testing.T) {
+  _, _, restErr := ApplyMergePatch(widgetProduct, []byte(`[]`))
+  assert.Error(t, restErr, `Expected error for non-object patch.`)
+  _, _, restErr = ApplyMergePatch(widgetProduct, []byte(`{"archived": true}`))
+  assert.Error(t, restErr, `Expected error patching read-only field.`)
+  _, _, restErr = ApplyMergePatch(widgetProduct, []byte(`{"summary": {"a": 1}}`))
+  assert.Error(t, restErr, `Expected error for non-string value.`)
+  _, _, restErr = ApplyMergePatch(widgetProduct, []byte(`{"pubId": "b"}`))
+  assert.Error(t, restErr, `Expected error changing pubId.`)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+  patch := `[
+    {"op": "test", "path": "/displayName", "value": "Widget"},
+    {"op": "replace", "path": "/summary", "value": "A much better dodad."},
+    {"op": "remove", "path": "/logoURL"},
+    {"op": "copy", "from": "/homepage", "path": "/issuesURL"}
+  ]`
+  patched, fields, restErr := ApplyJSONPatch(widgetProduct, []byte(patch))
+  require.NoError(t, restErr, `Unexpected error applying JSON patch.`)
+  assert.Equal(t, []string{`summary`, `logoURL`, `issuesURL`}, fields, `Unexpected touched fields.`)
+  assert.Equal(t, `A much better dodad.`, patched.Summary.String, `Unexpected summary.`)
+  assert.False(t, patched.LogoURL.Valid, `Logo URL not removed.`)
+  assert.Equal(t, widgetProduct.Homepage, patched.IssuesURL, `Issues URL not copied.`)
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+  _, _, restErr := ApplyJSONPatch(widgetProduct, []byte(`[{"op": "test", "path": "/displayName", "value": "Gadget"}]`))
+  require.Error(t, restErr, `Expected error on failed test.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on failed test.`)
+  _, _, restErr = ApplyJSONPatch(widgetProduct, []byte(`[{"op": "replace", "path": "/lastUpdated", "value": 3}]`))
+  assert.Error(t, restErr, `Expected error replacing read-only field.`)
+  _, _, restErr = ApplyJSONPatch(widgetProduct, []byte(`[{"op": "replace", "path": "/a/b", "value": "c"}]`))
+  assert.Error(t, restErr, `Expected error on nested path.`)
+  _, _, restErr = ApplyJSONPatch(widgetProduct, []byte(`[{"op": "frob", "path": "/summary"}]`))
+  assert.Error(t, restErr, `Expected error on unknown operation.`)
+}
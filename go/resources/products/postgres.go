@@ -0,0 +1,67 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+  _ "github.com/lib/pq" // registers the 'postgres' driver
+)
+
+// PostgresDialect supports PostgreSQL. As with MySQL, the 'entities' and
+// 'users' tables are expected to exist; see 'data/sql-postgres/schema'. The
+// products tables are created by the migrations. See OpenPostgres.
+var PostgresDialect = &Dialect{
+  Name: `postgres`,
+  placeholder: `$%d`,
+  likeOperator: `ILIKE`,
+  lockClause: ` FOR UPDATE`,
+  // Ensure each change produces a new version, even within the same second.
+  touchExpr: `GREATEST(last_updated + 1, CAST(EXTRACT(EPOCH FROM now()) AS BIGINT))`,
+  epochFormat: `CAST(EXTRACT(EPOCH FROM %s) AS BIGINT)`,
+  fullText: postgresFullText,
+  adoptionProbes: completeSchemaProbes,
+  createEntityInTxn: createPostgresEntityInTxn,
+  lockMigrations: lockPostgresMigrations,
+}
+
+// OpenPostgres opens the PostgreSQL database described by the 'lib/pq'
+// connection string and sets it up for use with PostgresDialect. If the
+// database is unreachable, or setup otherwise fails, the database is returned
+// along with the error; see SetupDBWithDialect.
+func OpenPostgres(dataSourceName string) (*sql.DB, error) {
+  db, err := sql.Open(`postgres`, dataSourceName)
+  if err != nil {
+    return nil, fmt.Errorf("postgres: open: %v", err)
+  }
+
+  return db, SetupDBWithDialect(db, PostgresDialect)
+}
+
+// The driver does not support LastInsertId.
+const createPostgresEntityStatement = `INSERT INTO entities (pub_id) VALUES ($1) RETURNING id`
+
+func createPostgresEntityInTxn(ctx context.Context, txn *sql.Tx) (int64, rest.RestError) {
+  pubId, err := newPubId()
+  if err != nil {
+    return 0, rest.ServerError("Could not generate entity ID.", err)
+  }
+  var id int64
+  if err := txn.QueryRowContext(ctx, createPostgresEntityStatement, pubId).Scan(&id); err != nil {
+    return 0, ClassifySQLError("Could not create entity.", err)
+  }
+  return id, nil
+}
+
+// postgresMigrationLock is the advisory lock key; "prod" in ASCII.
+const postgresMigrationLock = 0x70726f64
+
+// The advisory lock is held by the session rather than a transaction and
+// waits indefinitely, subject to the context.
+func lockPostgresMigrations(conn *sql.Conn, ctx context.Context) (func(), error) {
+  if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+    return nil, err
+  }
+  return func() { conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock) }, nil
+}
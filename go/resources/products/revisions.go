@@ -0,0 +1,209 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "fmt"
+
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// ProductRevision is an immutable snapshot of a Product as it stood after a
+// change, along with a description of the change and who made it. Revisions
+// are numbered sequentially per Product, starting with 1.
+type ProductRevision struct {
+  Revision   int64        `json:"revision"`
+  Product    *Product     `json:"product"`
+  ChangeDesc nulls.String `json:"changeDesc"`
+  Actor      nulls.String `json:"actor"`
+  // Created is the revision time in seconds since the epoch.
+  Created    nulls.Int64  `json:"created"`
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of the Context identifying the user making
+// changes. The actor is recorded with any ProductRevision created in the
+// Context. This is typically set by authentication middleware.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+  return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext retrieves the actor set by ContextWithActor, if any.
+func ActorFromContext(ctx context.Context) (string, bool) {
+  actor, ok := ctx.Value(actorKey{}).(string)
+  return actor, ok
+}
+
+const createRevisionStatement = `INSERT INTO product_revisions (product, revision, snapshot, change_desc, actor) SELECT ?, COALESCE(MAX(r.revision), 0) + 1, ?, ?, ? FROM product_revisions r WHERE r.product=?`
+
+// recordRevisionInTxn records the Product, as retrieved after a change, as the
+// next ProductRevision.
+func recordRevisionInTxn(p *Product, changeDesc nulls.String, ctx context.Context, txn *sql.Tx) rest.RestError {
+  snapshot := p.Clone()
+  snapshot.ChangeDesc = nulls.NewNullString()
+  snapshotJSON, err := json.Marshal(snapshot)
+  if err != nil {
+    return rest.ServerError("Could not encode product revision.", err)
+  }
+
+  actor := nulls.NewNullString()
+  if actorID, ok := ActorFromContext(ctx); ok {
+    actor = nulls.NewString(actorID)
+  }
+
+  if _, err := txn.StmtContext(ctx, currentStatements().createRevision).ExecContext(ctx, p.Id, string(snapshotJSON), changeDesc, actor, p.Id); err != nil {
+    return ClassifySQLError("Could not record product revision.", err)
+  }
+
+  return nil
+}
+
+// revisionStatements generates the statements retrieving all or one revision.
+// The creation time is converted to seconds since the epoch per the dialect.
+func revisionStatements(dialect *Dialect) (string, string) {
+  commonRevisionGet := `SELECT r.revision, r.snapshot, r.change_desc, r.actor, ` + fmt.Sprintf(dialect.epochFormat, `r.created_at`) + ` FROM product_revisions r JOIN entities e ON r.product=e.id `
+  return commonRevisionGet + `WHERE e.pub_id=? ORDER BY r.revision`, commonRevisionGet + `WHERE e.pub_id=? AND r.revision=?`
+}
+
+// ScanRevision scans a ProductRevision, decoding the Product snapshot.
+func ScanRevision(row *sql.Rows) (*ProductRevision, error) {
+  var r ProductRevision
+  var snapshot string
+
+  if err := row.Scan(&r.Revision, &snapshot, &r.ChangeDesc, &r.Actor, &r.Created); err != nil {
+    return nil, err
+  }
+  r.Product = &Product{}
+  if err := json.Unmarshal([]byte(snapshot), r.Product); err != nil {
+    return nil, err
+  }
+
+  return &r, nil
+}
+
+// GetProductRevisions retrieves the full revision history, oldest first, of
+// the Product identified by the public ID. The history of archived Products
+// remains available. Attempting to retrieve the history of a non-existent
+// Product results in a rest.NotFoundError.
+func GetProductRevisions(pubId string, ctx context.Context) ([]*ProductRevision, rest.RestError) {
+  if _, restErr := GetProductIncludeArchived(pubId, ctx); restErr != nil {
+    return nil, restErr
+  }
+
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
+  rows, err := currentStatements().getRevisions.QueryContext(ctx, pubId)
+  if err != nil {
+    return nil, ClassifySQLError("Error retrieving product revisions.", err)
+  }
+  defer rows.Close()
+
+  revisions := make([]*ProductRevision, 0)
+  for rows.Next() {
+    revision, err := ScanRevision(rows)
+    if err != nil {
+      return nil, rest.ServerError(fmt.Sprintf("Problem getting revisions for product: '%s'", pubId), err)
+    }
+    revisions = append(revisions, revision)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Error retrieving product revisions.", err)
+  }
+
+  return revisions, nil
+}
+
+// GetProductRevision retrieves the numbered revision of the Product identified
+// by the public ID. Attempting to retrieve a non-existent revision results in
+// a rest.NotFoundError.
+func GetProductRevision(pubId string, revision int64, ctx context.Context) (*ProductRevision, rest.RestError) {
+  return getRevisionHelper(pubId, revision, ctx, nil)
+}
+
+// GetProductRevisionInTxn retrieves a numbered ProductRevision in the context
+// of an existing transaction. See GetProductRevision.
+func GetProductRevisionInTxn(pubId string, revision int64, ctx context.Context, txn *sql.Tx) (*ProductRevision, rest.RestError) {
+  return getRevisionHelper(pubId, revision, ctx, txn)
+}
+
+func getRevisionHelper(pubId string, revision int64, ctx context.Context, txn *sql.Tx) (*ProductRevision, rest.RestError) {
+  stmt := currentStatements().getRevision
+  if txn != nil {
+    stmt = txn.StmtContext(ctx, stmt)
+  } else {
+    var cancel context.CancelFunc
+    ctx, cancel = operationContext(ctx)
+    defer cancel()
+  }
+  rows, err := stmt.QueryContext(ctx, pubId, revision)
+  if err != nil {
+    return nil, ClassifySQLError("Error retrieving product revision.", err)
+  }
+  defer rows.Close()
+
+  if !rows.Next() {
+    if err := rows.Err(); err != nil {
+      return nil, ClassifySQLError("Error retrieving product revision.", err)
+    }
+    return nil, rest.NotFoundError(fmt.Sprintf(`Revision %d of product '%s' not found.`, revision, pubId), nil)
+  }
+  productRevision, err := ScanRevision(rows)
+  if err != nil {
+    return nil, rest.ServerError(fmt.Sprintf("Problem getting revision %d for product: '%s'", revision, pubId), err)
+  }
+
+  return productRevision, nil
+}
+
+// DiffProductRevisions reports the fields which differ between two revisions
+// of the Product identified by the public ID. See DiffProducts.
+func DiffProductRevisions(pubId string, from int64, to int64, ctx context.Context) ([]FieldDiff, rest.RestError) {
+  fromRevision, restErr := GetProductRevision(pubId, from, ctx)
+  if restErr != nil {
+    return nil, restErr
+  }
+  toRevision, restErr := GetProductRevision(pubId, to, ctx)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  return DiffProducts(fromRevision.Product, toRevision.Product), nil
+}
+
+// RevertProduct restores the Product fields to those recorded in the numbered
+// revision. The revert is applied as a normal update: 'lastUpdated' must match
+// the current record and the revert is itself recorded as a new revision. If
+// 'changeDesc' is null, a description noting the revert is used.
+func RevertProduct(pubId string, revision int64, lastUpdated nulls.Int64, changeDesc nulls.String, ctx context.Context) (*Product, rest.RestError) {
+  var newP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    newP, restErr = RevertProductInTxn(pubId, revision, lastUpdated, changeDesc, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
+  }
+  return newP, nil
+}
+
+// RevertProductInTxn reverts a Product to a prior revision within an existing
+// transaction. See RevertProduct.
+func RevertProductInTxn(pubId string, revision int64, lastUpdated nulls.Int64, changeDesc nulls.String, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  productRevision, restErr := GetProductRevisionInTxn(pubId, revision, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  reverted := productRevision.Product.Clone()
+  reverted.PubId = nulls.NewString(pubId)
+  reverted.LastUpdated = lastUpdated
+  reverted.ChangeDesc = changeDesc
+  if !changeDesc.Valid {
+    reverted.SetChangeDesc(fmt.Sprintf(`Reverted to revision %d.`, revision))
+  }
+
+  return UpdateProductInTxn(reverted, ctx, txn)
+}
@@ -0,0 +1,196 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "fmt"
+  "log"
+  "sync"
+  "time"
+)
+
+// SetupRetryInterval is the time between attempts to set up the database
+// after setup fails. Each attempt waits on the database to be reachable.
+var SetupRetryInterval = 30 * time.Second
+
+// setupMutex guards the setup status and current statements. The generation
+// identifies the latest setup, so that superseded retries stop.
+var setupMutex sync.RWMutex
+var setupErr error = errors.New(`database not set up`)
+var setupGeneration int
+
+// current holds the statements of the latest successful setup, along with the
+// database and dialect. Until then, only the (default) dialect is set.
+var current = &productStatements{dialect: MySQLDialect}
+
+// currentStatements returns the statements of the latest successful setup.
+func currentStatements() *productStatements {
+  setupMutex.RLock()
+  defer setupMutex.RUnlock()
+  return current
+}
+
+// productsDB returns the database set up by SetupDB or SetupDBWithDialect.
+func productsDB() *sql.DB {
+  return currentStatements().db
+}
+
+// sqlDialect returns the dialect set up by SetupDBWithDialect.
+func sqlDialect() *Dialect {
+  return currentStatements().dialect
+}
+
+// StoreUnavailable returns the reason the SQL store cannot be used, or nil if
+// it is set up and ready.
+func StoreUnavailable() error {
+  setupMutex.RLock()
+  defer setupMutex.RUnlock()
+  return setupErr
+}
+
+// SetupDB migrates, or verifies, the products schema and prepares the product
+// statements against the MySQL database. This is suitable for
+// 'sqldb.RegisterSetup'. A failure is logged rather than fatal; the products
+// API reports the store unavailable while setup is retried. See
+// SetupDBWithDialect.
+func SetupDB(db *sql.DB) {
+  if err := SetupDBWithDialect(db, MySQLDialect); err != nil {
+    log.Printf("Products store unavailable: %v", err)
+  }
+}
+
+// SetupDBWithDialect migrates, or verifies, the products schema and prepares
+// the product statements against a database of the given dialect. See
+// MigrateOnSetup. If setup fails, the error is returned and setup is retried
+// every SetupRetryInterval, once the database is reachable, until it
+// succeeds. Meanwhile, StoreUnavailable reports the failure.
+func SetupDBWithDialect(db *sql.DB, dialect *Dialect) error {
+  setupMutex.Lock()
+  setupGeneration += 1
+  generation := setupGeneration
+  setupMutex.Unlock()
+
+  err := setupDBInGeneration(db, dialect, generation)
+  if err != nil {
+    go retrySetup(db, dialect, generation)
+  }
+  return err
+}
+
+func retrySetup(db *sql.DB, dialect *Dialect, generation int) {
+  for {
+    time.Sleep(SetupRetryInterval)
+    if superseded(generation) {
+      return
+    }
+    if err := db.Ping(); err != nil {
+      continue
+    }
+    if err := setupDBInGeneration(db, dialect, generation); err == nil {
+      log.Printf("Products store available.")
+      return
+    } else {
+      log.Printf("Products store still unavailable: %v", err)
+    }
+  }
+}
+
+func superseded(generation int) bool {
+  setupMutex.RLock()
+  defer setupMutex.RUnlock()
+  return setupGeneration != generation
+}
+
+// setupDBInGeneration sets up the database and, unless a later setup has
+// begun, updates the store status and statements.
+func setupDBInGeneration(db *sql.DB, dialect *Dialect, generation int) error {
+  var err error
+  if MigrateOnSetup {
+    err = MigrateSchema(db, dialect, context.Background())
+  } else {
+    err = VerifySchema(db, dialect, context.Background())
+  }
+  var statements *productStatements
+  if err == nil {
+    statements, err = prepareProductStatements(db, dialect)
+  }
+
+  setupMutex.Lock()
+  defer setupMutex.Unlock()
+  if setupGeneration != generation {
+    statements.close()
+    return err
+  }
+  setupErr = err
+  if err == nil {
+    statements.install()
+  }
+  return err
+}
+
+// productStatements holds the prepared statements, and the database and
+// dialect they were prepared for, until all are ready.
+type productStatements struct {
+  db      *sql.DB
+  dialect *Dialect
+  createProduct, getProduct, getProductIncludeArchived, getProductById, updateProduct, archiveProduct, lockProduct, touchProduct *sql.Stmt
+  createRevision, getRevisions, getRevision *sql.Stmt
+  all []*sql.Stmt
+}
+
+func prepareProductStatements(db *sql.DB, dialect *Dialect) (*productStatements, error) {
+  statements := &productStatements{db: db, dialect: dialect}
+  var err error
+  prepare := func(desc string, query string) *sql.Stmt {
+    if err != nil {
+      return nil
+    }
+    stmt, prepErr := db.Prepare(dialect.Rebind(query))
+    if prepErr != nil {
+      // The reason is reported to clients, so the query is only logged.
+      log.Printf("%s: prepare %s stmt:\n%v\n%s", dialect.Name, desc, prepErr, query)
+      err = fmt.Errorf("%s: prepare %s stmt: %v", dialect.Name, desc, prepErr)
+      return nil
+    }
+    statements.all = append(statements.all, stmt)
+    return stmt
+  }
+
+  getRevisionsStatement, getRevisionStatement := revisionStatements(dialect)
+  statements.createProduct = prepare(`create product`, createProductStatement)
+  statements.getProduct = prepare(`get product`, getProductStatement)
+  statements.getProductIncludeArchived = prepare(`get product including archived`, getProductIncludeArchivedStatement)
+  statements.getProductById = prepare(`get product by ID`, getProductByIdStatement)
+  statements.updateProduct = prepare(`update product`, updateProductStatement)
+  statements.archiveProduct = prepare(`archive product`, archiveProductStatement)
+  statements.lockProduct = prepare(`lock product`, lockProductStatement + dialect.lockClause)
+  statements.touchProduct = prepare(`touch product`, `UPDATE entities SET last_updated=` + dialect.touchExpr + ` WHERE id=?`)
+  statements.createRevision = prepare(`create product revision`, createRevisionStatement)
+  statements.getRevisions = prepare(`get product revisions`, getRevisionsStatement)
+  statements.getRevision = prepare(`get product revision`, getRevisionStatement)
+  if err != nil {
+    statements.close()
+    return nil, err
+  }
+  return statements, nil
+}
+
+// install makes the statements current, closing those they replace. An
+// operation still using a replaced statement fails, but setup is only
+// repeated while the store is unavailable or when the database is replaced.
+// The caller must hold the setup lock.
+func (s *productStatements) install() {
+  replaced := current
+  current = s
+  replaced.close()
+}
+
+func (s *productStatements) close() {
+  if s == nil {
+    return
+  }
+  for _, stmt := range s.all {
+    stmt.Close()
+  }
+}
@@ -0,0 +1,79 @@
+package products_test
+
+import (
+  "context"
+  "database/sql"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+  "time"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/gorilla/mux"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestSetupUnavailable(t *testing.T) {
+  path := filepath.Join(t.TempDir(), `products.db`)
+  // A changed migration causes setup to fail.
+  blocker, err := sql.Open(`sqlite`, `file:` + path)
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer blocker.Close()
+  _, err = blocker.Exec(`CREATE TABLE products_schema_version (version INTEGER NOT NULL PRIMARY KEY, name VARCHAR(128) NOT NULL, checksum CHAR(64) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);
+    INSERT INTO products_schema_version (version, name, checksum) VALUES (1, 'initial', 'changed')`)
+  require.NoError(t, err, `Unexpected error creating schema version.`)
+
+  defer func(interval time.Duration) { SetupRetryInterval = interval }(SetupRetryInterval)
+  SetupRetryInterval = 10 * time.Millisecond
+  db, err := OpenSQLite(path)
+  require.Error(t, err, `Unexpected success setting up database.`)
+  require.NotNil(t, db, `Expected database despite setup failure.`)
+  defer db.Close()
+  assert.Error(t, StoreUnavailable(), `Expected store to be unavailable.`)
+
+  r := mux.NewRouter()
+  InitAPI(r)
+  ping := func() *httptest.ResponseRecorder {
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(`PING`, `/products/`, nil))
+    return w
+  }
+  w := ping()
+  assert.Equal(t, http.StatusServiceUnavailable, w.Code, `Unexpected status while unavailable.`)
+  assert.Contains(t, w.Body.String(), `has changed since it was applied`, `Expected reason in response.`)
+  assert.NotEmpty(t, w.Header().Get(`Retry-After`), `Expected 'Retry-After' header.`)
+
+  // Once the problem is corrected, setup is retried.
+  _, err = blocker.Exec(`DELETE FROM products_schema_version`)
+  require.NoError(t, err, `Unexpected error correcting schema version.`)
+  deadline := time.Now().Add(5 * time.Second)
+  for StoreUnavailable() != nil && time.Now().Before(deadline) {
+    time.Sleep(10 * time.Millisecond)
+  }
+  require.NoError(t, StoreUnavailable(), `Store still unavailable after correction.`)
+  assert.Equal(t, http.StatusOK, ping().Code, `Unexpected status once available.`)
+}
+
+// Setup may be repeated while the store is in use; see 'go test -race'.
+func TestSetupRepeated(t *testing.T) {
+  db, err := OpenSQLite(filepath.Join(t.TempDir(), `products.db`))
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+
+  done := make(chan struct{})
+  go func() {
+    defer close(done)
+    for i := 0; i < 100; i++ {
+      // A replaced statement may fail, so only the access matters.
+      GetProduct(someProductID, context.Background())
+    }
+  }()
+  for i := 0; i < 5; i++ {
+    require.NoError(t, SetupDBWithDialect(db, SQLiteDialect), `Unexpected error repeating setup.`)
+  }
+  <-done
+  _, restErr := GetProduct(someProductID, context.Background())
+  assert.Equal(t, http.StatusNotFound, restErr.Code(), `Unexpected error after repeated setup.`)
+}
@@ -4,24 +4,28 @@ import (
   "context"
   "database/sql"
   "fmt"
-  "log"
+  "strings"
 
-  "github.com/Liquid-Labs/go-api/sqldb"
   "github.com/Liquid-Labs/go-nullable-mysql/nulls"
   "github.com/Liquid-Labs/go-rest/rest"
-  "github.com/Liquid-Labs/catalyst-core-api/go/resources/entities"
 )
 
+// ProductsSorts gives the ORDER BY expression for each supported sort. Ties
+// are broken by internal ID so that the order is stable; see productsSortKeys.
+//
+// The 'relevance' sort orders the results of a full-text search by relevance,
+// and then by name; other searches are ordered by name alone.
 var ProductsSorts = map[string]string{
-  "": `p.display_name ASC `,
-  `name-asc`: `p.display_name ASC `,
-  `name-desc`: `p.display_name DESC `,
+  "": `p.display_name ASC, p.id ASC `,
+  `name-asc`: `p.display_name ASC, p.id ASC `,
+  `name-desc`: `p.display_name DESC, p.id DESC `,
+  `relevance`: `p.display_name ASC, p.id ASC `,
 }
 
 func ScanProduct(row *sql.Rows) (*Product, error) {
 	var p Product
 
-	if err := row.Scan(&p.Id, &p.PubId, &p.LastUpdated, &p.LegalOwnerPubID, &p.DisplayName, &p.Summary, &p.SupportPhone, &p.SupportEmail, &p.Homepage, &p.LogoURL, &p.RepoURL, &p.IssuesURL, &p.Ontology); err != nil {
+	if err := row.Scan(&p.Id, &p.PubId, &p.LastUpdated, &p.LegalOwnerPubID, &p.DisplayName, &p.Summary, &p.SupportPhone, &p.SupportEmail, &p.Homepage, &p.LogoURL, &p.RepoURL, &p.IssuesURL, &p.Ontology, &p.Archived); err != nil {
 		return nil, err
 	}
 
@@ -43,76 +47,269 @@ func BuildProductResults(rows *sql.Rows) (interface{}, error) {
   return results, nil
 }
 
-// Implements rest.GeneralSearchWhereBit
+// Implements rest.GeneralSearchWhereBit. The match ignores case in all
+// dialects.
 func ProductsGeneralWhereGenerator(term string, params []interface{}) (string, []interface{}, error) {
   likeTerm := `%`+term+`%`
-  var whereBit string = "AND (p.display_name LIKE ? OR p.summary LIKE ?) "
+  like := sqlDialect().likeOperator
+  var whereBit string = "AND (p.display_name " + like + " ? OR p.summary " + like + " ?) "
   params = append(params, likeTerm, likeTerm)
 
   return whereBit, params, nil
 }
 
-const CommonProductFields = `e.id, e.pub_id, e.last_updated, lo.pub_id, p.display_name, p.summary, p.support_phone, p.support_email, p.homepage, p.logo_url, p.repo_url, p.issues_url, p.ontology `
-const CommonProductsFrom = `FROM products p JOIN entities e ON p.id=e.id JOIN entities lo ON p.legal_owner=lo.id `
+// ListProducts retrieves the page of Products described by the search
+// parameters. The search terms, if any, are matched per the filters
+// SearchMode, by default using ProductsGeneralWhereGenerator, and the results
+// ordered per ProductsSorts. The
+// total item and page counts are set on the 'searchParams.PageInfo' as a
+// side effect. The Products are further limited by the filters, if any, and
+// archived Products are excluded unless 'includeArchived' is true.
+func ListProducts(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*Product, rest.RestError) {
+  bits, restErr := buildSearchBits(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
 
-const createProductStatement = `INSERT INTO products (id, legal_owner, display_name, summary, support_phone, support_email, homepage, logo_url, repo_url, issues_url, ontology) SELECT ?,lo.id,?,?,?,?,?,?,?,?,? FROM entities lo WHERE lo.pub_id=?`
-func CreateProduct(p *Product, ctx context.Context) (*Product, rest.RestError) {
-  txn, err := sqldb.DB.Begin()
+  var count int64
+  countQuery := `SELECT COUNT(*) ` + CommonProductsFrom + bits.where
+  if err := productsDB().QueryRowContext(ctx, sqlDialect().Rebind(countQuery), bits.params...).Scan(&count); err != nil {
+    return nil, ClassifySQLError("Could not count products.", err)
+  }
+  searchParams.SetTotalPages(count)
+
+  pageInfo := searchParams.PageInfo
+  listQuery := CommonProductGet + bits.where + `ORDER BY ` + bits.order + `LIMIT ? OFFSET ?`
+  params := append(append(bits.params, bits.orderParams...), pageInfo.ItemsPerPage, (pageInfo.PageIndex - 1) * pageInfo.ItemsPerPage)
+  rows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(listQuery), params...)
+  if err != nil {
+    return nil, ClassifySQLError("Error retrieving products.", err)
+  }
+  defer rows.Close()
+
+  results, err := BuildProductResults(rows)
+  if err != nil {
+    return nil, rest.ServerError("Problem getting data for products.", err)
+  } else if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Error retrieving products.", err)
+  }
+  products := results.([]*Product)
+  for _, product := range products {
+    bits.formatOut(product)
+  }
+
+  return products, nil
+}
+
+// searchBits are the generated parts of a listing query.
+type searchBits struct {
+  // where is the WHERE clause, taking 'params'.
+  where       string
+  params      []interface{}
+  // order is the ORDER BY expression, taking 'orderParams'.
+  order       string
+  orderParams []interface{}
+  // words are the words of a full-text search, if any.
+  words       []fullTextWord
+}
+
+// formatOut formats the Product for output and, for a full-text search, sets
+// the highlighted Snippet.
+func (bits *searchBits) formatOut(p *Product) {
+  p.FormatOut()
+  if bits.words != nil {
+    p.Snippet = highlightSnippet(p.Summary.String, bits.words)
+  }
+}
+
+// buildSearchBits generates the WHERE clause, ORDER BY expression, and query
+// parameters for the search and filters.
+func buildSearchBits(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters) (*searchBits, rest.RestError) {
+  sort, ok := ProductsSorts[searchParams.Sort]
+  if !ok {
+    return nil, rest.BadRequestError(fmt.Sprintf(`Unknown sort '%s'.`, searchParams.Sort), nil)
+  }
+
+  var whereBit string = `WHERE 1=1 `
+  if !includeArchived {
+    whereBit += `AND NOT p.archived `
+  }
+  filterBit, params, restErr := filters.bits(make([]interface{}, 0))
+  if restErr != nil {
+    return nil, restErr
+  }
+  bits := &searchBits{where: whereBit + filterBit, params: params, order: sort}
+
+  mode := SearchSubstring
+  if filters != nil {
+    mode = filters.SearchMode // set by 'bits'
+  }
+  var ranks []string
+  for _, term := range searchParams.Terms {
+    if mode == SearchSubstring {
+      termBit, termParams, err := ProductsGeneralWhereGenerator(term, bits.params)
+      if err != nil {
+        return nil, rest.BadRequestError(fmt.Sprintf(`Could not process search term '%s'.`, term), err)
+      }
+      bits.where += termBit
+      bits.params = termParams
+      continue
+    }
+
+    boolean := mode == SearchBoolean
+    match, rank, termParams := sqlDialect().fullText(term, boolean)
+    bits.where += `AND ` + match + ` `
+    bits.params = append(bits.params, termParams...)
+    ranks = append(ranks, rank)
+    bits.orderParams = append(bits.orderParams, termParams...)
+    bits.words = append(bits.words, parseFullTextTerm(term, boolean)...)
+  }
+  if searchParams.Sort == `relevance` && len(ranks) > 0 {
+    bits.order = strings.Join(ranks, ` + `) + ` DESC, ` + sort
+  } else {
+    bits.orderParams = nil
+  }
+
+  return bits, nil
+}
+
+// ExportProducts passes every Product matching the search terms and filters,
+// in sort order, to 'emit'. Unlike ListProducts, the results are not paged or
+// gathered; each Product is scanned and emitted in turn, so the entire catalog
+// may be streamed without holding it in memory. The Products are formatted
+// with FormatOut, as for retrieval. If 'emit' returns an error, the export
+// stops and the error is returned as a rest.ServerError.
+//
+// If 'cursor' is not empty, the export starts after the position it encodes,
+// so an interrupted export may be resumed from the CursorAfter the last
+// Product received.
+//
+// Since an export may legitimately run long, QueryTimeout is not applied; the
+// export stops only if 'ctx' is canceled.
+func ExportProducts(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, cursor string, emit func(*Product) error, ctx context.Context) rest.RestError {
+  bits, restErr := buildSearchBits(searchParams, includeArchived, filters)
+  if restErr != nil {
+    return restErr
+  }
+  whereBit, order, params := bits.where, bits.order, append(bits.params, bits.orderParams...)
+  if cursor != `` {
+    key, position, restErr := decodeCursor(cursor, searchParams.Sort)
+    if restErr != nil {
+      return restErr
+    } else if position.Backward {
+      return rest.BadRequestError(`Export cursor must be a following ('next') cursor.`, nil)
+    }
+    var keyBit string
+    keyBit, order, params = key.bits(position, bits.params)
+    whereBit += keyBit
+  }
+
+  rows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(CommonProductGet + whereBit + `ORDER BY ` + order), params...)
   if err != nil {
-    defer txn.Rollback()
-    return nil, rest.ServerError("Could not create product record. (txn error)", err)
+    return ClassifySQLError("Error retrieving products.", err)
   }
-  newP, restErr := CreateProductInTxn(p, ctx, txn)
-  // txn already rolled back if in error, so we only need to commit if no error
-  if err == nil {
-    defer txn.Commit()
+  defer rows.Close()
+
+  for rows.Next() {
+    product, err := ScanProduct(rows)
+    if err != nil {
+      return rest.ServerError("Problem getting data for products.", err)
+    }
+    product.FormatOut()
+    if err := emit(product); err != nil {
+      return rest.ServerError("Problem exporting products.", err)
+    }
+  }
+  if err := rows.Err(); err != nil {
+    return ClassifySQLError("Problem reading products.", err)
   }
-  return newP, restErr
+
+  return nil
+}
+
+const CommonProductFields = `e.id, e.pub_id, e.last_updated, lo.pub_id, p.display_name, p.summary, p.support_phone, p.support_email, p.homepage, p.logo_url, p.repo_url, p.issues_url, p.ontology, p.archived `
+const CommonProductsFrom = `FROM products p JOIN entities e ON p.id=e.id JOIN entities lo ON p.legal_owner=lo.id `
+
+const createProductStatement = `INSERT INTO products (id, legal_owner, display_name, summary, support_phone, support_email, homepage, logo_url, repo_url, issues_url, ontology) VALUES (?,?,?,?,?,?,?,?,?,?,?)`
+func CreateProduct(p *Product, ctx context.Context) (*Product, rest.RestError) {
+  var newP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    newP, restErr = CreateProductInTxn(p, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
+  }
+  return newP, nil
 }
 
 func CreateProductInTxn(p *Product, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
-  var err error
-  newId, restErr := entities.CreateEntityInTxn(txn)
+  if validationErr := p.Validate(); validationErr != nil {
+    return nil, *validationErr
+  }
+
+  legalOwnerId, restErr := resolveLegalOwnerInTxn(p.LegalOwnerPubID.String, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  newId, restErr := sqlDialect().createEntityInTxn(ctx, txn)
   if restErr != nil {
-    defer txn.Rollback()
 		return nil, restErr
   }
 
   p.Id = nulls.NewInt64(newId)
 
-	_, err = txn.Stmt(createProductQuery).Exec(newId, p.DisplayName, p.Summary, p.SupportPhone, p.SupportEmail, p.Homepage, p.LogoURL, p.RepoURL, p.IssuesURL, p.Ontology, p.LegalOwnerPubID)
+	_, err := txn.StmtContext(ctx, currentStatements().createProduct).ExecContext(ctx, newId, legalOwnerId, p.DisplayName, p.Summary, p.storedPhone(), p.SupportEmail, p.Homepage, p.LogoURL, p.RepoURL, p.IssuesURL, p.Ontology)
 	if err != nil {
-    // TODO: can we do more to tell the cause of the failure? We assume it's due to malformed data with the HTTP code
-    defer txn.Rollback()
-    log.Print(err)
-		return nil, rest.UnprocessableEntityError("Failure creating product.", err)
+		return nil, ClassifySQLError("Failure creating product.", err)
 	}
 
-  newProduct, err := GetProductByIDInTxn(p.Id.Int64, ctx, txn)
-  if err != nil {
-    return nil, rest.ServerError("Problem retrieving newly updated product.", err)
+  newProduct, restErr := GetProductByIDInTxn(p.Id.Int64, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+  if restErr := recordRevisionInTxn(newProduct, p.ChangeDesc, ctx, txn); restErr != nil {
+    return nil, restErr
   }
 
   return newProduct, nil
 }
 
 const CommonProductGet string = `SELECT ` + CommonProductFields + CommonProductsFrom
-const getProductStatement string = CommonProductGet + `WHERE e.pub_id=? `
+const getProductStatement string = CommonProductGet + `WHERE e.pub_id=? AND NOT p.archived `
 
 // GetProduct retrieves a Product from a public ID string (UUID). Attempting to
-// retrieve a non-existent Product results in a rest.NotFoundError. This is used
-// primarily to retrieve a Product in response to an API request.
+// retrieve a non-existent or archived Product results in a rest.NotFoundError.
+// This is used primarily to retrieve a Product in response to an API request.
 //
 // Consider using GetProductByID to retrieve a Product from another backend/DB
 // function. TODO: reference discussion of internal vs public IDs.
 func GetProduct(pubId string, ctx context.Context) (*Product, rest.RestError) {
-  return getProductHelper(getProductQuery, pubId, ctx, nil)
+  return getProductHelper(currentStatements().getProduct, pubId, ctx, nil)
 }
 
 // GetProductInTxn retrieves a Product by public ID string (UUID) in the context
 // of an existing transaction. See GetProduct.
 func GetProductInTxn(pubId string, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
-  return getProductHelper(getProductQuery, pubId, ctx, txn)
+  return getProductHelper(currentStatements().getProduct, pubId, ctx, txn)
+}
+
+const getProductIncludeArchivedStatement string = CommonProductGet + `WHERE e.pub_id=? `
+
+// GetProductIncludeArchived retrieves a Product by public ID string (UUID)
+// whether or not it has been archived. See GetProduct.
+func GetProductIncludeArchived(pubId string, ctx context.Context) (*Product, rest.RestError) {
+  return getProductHelper(currentStatements().getProductIncludeArchived, pubId, ctx, nil)
+}
+
+// GetProductIncludeArchivedInTxn retrieves a Product by public ID string
+// (UUID), whether or not it has been archived, in the context of an existing
+// transaction. See GetProductIncludeArchived.
+func GetProductIncludeArchivedInTxn(pubId string, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  return getProductHelper(currentStatements().getProductIncludeArchived, pubId, ctx, txn)
 }
 
 const getProductByIdStatement string = CommonProductGet + ` WHERE p.id=? `
@@ -125,22 +322,26 @@ const getProductByIdStatement string = CommonProductGet + ` WHERE p.id=? `
 // Use GetProduct to retrieve a Product in response to an API request. TODO:
 // reference discussion of internal vs public IDs.
 func GetProductByID(id int64, ctx context.Context) (*Product, rest.RestError) {
-  return getProductHelper(getProductByIdQuery, id, ctx, nil)
+  return getProductHelper(currentStatements().getProductById, id, ctx, nil)
 }
 
 // GetProductByIDInTxn retrieves a Product by internal ID in the context of an
 // existing transaction. See GetProductByID.
 func GetProductByIDInTxn(id int64, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
-  return getProductHelper(getProductByIdQuery, id, ctx, txn)
+  return getProductHelper(currentStatements().getProductById, id, ctx, txn)
 }
 
 func getProductHelper(stmt *sql.Stmt, id interface{}, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
   if txn != nil {
-    stmt = txn.Stmt(stmt)
+    stmt = txn.StmtContext(ctx, stmt)
+  } else {
+    var cancel context.CancelFunc
+    ctx, cancel = operationContext(ctx)
+    defer cancel()
   }
 	rows, err := stmt.QueryContext(ctx, id)
 	if err != nil {
-		return nil, rest.ServerError("Error retrieving product.", err)
+		return nil, ClassifySQLError("Error retrieving product.", err)
 	}
 	defer rows.Close()
 
@@ -154,6 +355,9 @@ func getProductHelper(stmt *sql.Stmt, id interface{}, ctx context.Context, txn *
       return nil, rest.ServerError(fmt.Sprintf("Problem getting data for product: '%v'", id), err)
     }
 	} // TODO: we expect a single row
+  if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Error retrieving product.", err)
+  }
   if product != nil {
     product.FormatOut()
     return product, nil
@@ -168,58 +372,307 @@ func getProductHelper(stmt *sql.Stmt, id interface{}, ctx context.Context, txn *
 
 // UpdatesProduct updates the canonical Product record. Attempting to update a
 // non-existent Product results in a rest.NotFoundError.
+//
+// The 'LastUpdated' value of the Product must match the stored record. If the
+// record has been modified in the meantime, the update is rejected with a 409
+// (conflict) error and the caller should re-retrieve the Product and re-apply
+// their changes. A Product without 'LastUpdated' results in a 428
+// (precondition required) error.
 func UpdateProduct(p *Product, ctx context.Context) (*Product, rest.RestError) {
-  txn, err := sqldb.DB.Begin()
-  if err != nil {
-    defer txn.Rollback()
-    return nil, rest.ServerError("Could not update product record.", err)
-  }
-
-  newP, restErr := UpdateProductInTxn(p, ctx, txn)
-  // txn already rolled back if in error, so we only need to commit if no error
-  if restErr == nil {
-    defer txn.Commit()
+  var newP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    newP, restErr = UpdateProductInTxn(p, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
   }
-
-  return newP, restErr
+  return newP, nil
 }
 
 // UpdatesProductInTxn updates the canonical Product record within an existing
 // transaction. See UpdateProduct.
 func UpdateProductInTxn(p *Product, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
-  var err error
-  var updateStmt *sql.Stmt = txn.Stmt(updateProductQuery)
-  _, err = updateStmt.Exec(p.LegalOwnerPubID, p.DisplayName, p.Summary, p.SupportPhone, p.SupportEmail, p.Homepage, p.LogoURL, p.RepoURL, p.IssuesURL, p.Ontology, p.PubId)
-  if err != nil {
-    if txn != nil {
-      defer txn.Rollback()
-    }
-    return nil, rest.ServerError("Could not update product record.", err)
+  if validationErr := p.Validate(); validationErr != nil {
+    return nil, *validationErr
+  }
+  id, restErr := checkProductVersionInTxn(p.PubId.String, p.LastUpdated, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+  legalOwnerId, restErr := resolveLegalOwnerInTxn(p.LegalOwnerPubID.String, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
   }
 
-  newProduct, err := GetProductInTxn(p.PubId.String, ctx, txn)
-  if err != nil {
-    return nil, rest.ServerError("Problem retrieving newly updated product.", err)
+  var updateStmt *sql.Stmt = txn.StmtContext(ctx, currentStatements().updateProduct)
+  if _, err := updateStmt.ExecContext(ctx, legalOwnerId, p.DisplayName, p.Summary, p.storedPhone(), p.SupportEmail, p.Homepage, p.LogoURL, p.RepoURL, p.IssuesURL, p.Ontology, id); err != nil {
+    return nil, ClassifySQLError("Could not update product record.", err)
+  }
+  if restErr := touchProductInTxn(id, ctx, txn); restErr != nil {
+    return nil, restErr
+  }
+
+  newProduct, restErr := GetProductInTxn(p.PubId.String, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+  if restErr := recordRevisionInTxn(newProduct, p.ChangeDesc, ctx, txn); restErr != nil {
+    return nil, restErr
   }
 
   return newProduct, nil
 }
 
 // TODO: enable update of AuthID
-const updateProductStatement = `UPDATE products p JOIN entities e ON p.id=e.id JOIN entities lo ON p.legal_owner=lo.id AND lo.pub_id=? SET p.legal_owner=lo.id, p.display_name=?, p.summary=?, p.support_phone=?, p.support_email=?, p.homepage=?, p.logo_url=?, p.repo_url=?, p.issues_url=?, p.ontology=?, e.last_updated=0 WHERE e.pub_id=?`
-var createProductQuery, updateProductQuery, getProductQuery, getProductByAuthIdQuery, getProductByIdQuery *sql.Stmt
-func SetupDB(db *sql.DB) {
-  var err error
-  if createProductQuery, err = db.Prepare(createProductStatement); err != nil {
-    log.Fatalf("mysql: prepare create product stmt:\n%v\n%s", err, createProductStatement)
+// The 'products' and 'entities' tables are updated separately (see
+// touchProductInTxn) as not all dialects support multi-table UPDATEs.
+const updateProductStatement = `UPDATE products SET legal_owner=?, display_name=?, summary=?, support_phone=?, support_email=?, homepage=?, logo_url=?, repo_url=?, issues_url=?, ontology=? WHERE id=?`
+
+// PatchProduct updates only the named fields of the canonical Product record,
+// leaving all other fields untouched. The fields are identified by their JSON
+// names, as returned by ApplyMergePatch and ApplyJSONPatch. As with
+// UpdateProduct, the Product 'LastUpdated' must match the stored record.
+func PatchProduct(p *Product, fields []string, ctx context.Context) (*Product, rest.RestError) {
+  var newP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    newP, restErr = PatchProductInTxn(p, fields, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
+  }
+  return newP, nil
+}
+
+// PatchProductWith locks and retrieves the Product, applies the patch function
+// to it, and saves the touched fields, all within a single transaction, so
+// the patch is applied to the Product as saved. As with PatchProduct, the
+// patched Product 'LastUpdated' must match the stored record. The patch
+// function may be called more than once, should the transaction be retried.
+func PatchProductWith(pubId string, patch func(*Product) (*Product, []string, rest.RestError), ctx context.Context) (*Product, rest.RestError) {
+  var newP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) rest.RestError {
+    if _, _, restErr := lockProductInTxn(pubId, ctx, txn); restErr != nil {
+      return restErr
+    }
+    current, restErr := GetProductInTxn(pubId, ctx, txn)
+    if restErr != nil {
+      return restErr
+    }
+    patched, fields, restErr := patch(current)
+    if restErr != nil {
+      return restErr
+    }
+    newP, restErr = PatchProductInTxn(patched, fields, ctx, txn)
+    return restErr
+  })
+  if restErr != nil {
+    return nil, restErr
+  }
+  return newP, nil
+}
+
+// PatchProductInTxn updates the named fields of the canonical Product record
+// within an existing transaction. See PatchProduct.
+func PatchProductInTxn(p *Product, fields []string, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  if validationErr := p.ValidateFields(fields); validationErr != nil {
+    return nil, *validationErr
+  }
+  id, restErr := checkProductVersionInTxn(p.PubId.String, p.LastUpdated, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+
+  if len(fields) > 0 {
+    patchStatement, params, restErr := buildPatchStatement(p, id, fields, ctx, txn)
+    if restErr != nil {
+      return nil, restErr
+    }
+    if _, err := txn.ExecContext(ctx, sqlDialect().Rebind(patchStatement), params...); err != nil {
+      return nil, ClassifySQLError("Could not update product record.", err)
+    }
+    if restErr := touchProductInTxn(id, ctx, txn); restErr != nil {
+      return nil, restErr
+    }
+  }
+
+  newProduct, restErr := GetProductInTxn(p.PubId.String, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+  if len(fields) > 0 {
+    if restErr := recordRevisionInTxn(newProduct, p.ChangeDesc, ctx, txn); restErr != nil {
+      return nil, restErr
+    }
+  }
+
+  return newProduct, nil
+}
+
+// buildPatchStatement generates an UPDATE setting only the named fields of the
+// Product with the internal ID. The column names come from productFields, so
+// no user input reaches the SQL text. The legal owner, if named, is resolved
+// within the transaction.
+func buildPatchStatement(p *Product, id int64, fields []string, ctx context.Context, txn *sql.Tx) (string, []interface{}, rest.RestError) {
+  setBits := make([]string, 0, len(fields))
+  params := make([]interface{}, 0, len(fields) + 1)
+  for _, name := range fields {
+    field, ok := findProductField(name)
+    if !ok {
+      return ``, nil, rest.UnprocessableEntityError(fmt.Sprintf(`Product field '%s' is unknown or read-only.`, name), nil)
+    }
+    setBits = append(setBits, field.column + `=?`)
+    if field.jsonName == `legalOwnerPubID` {
+      legalOwnerId, restErr := resolveLegalOwnerInTxn(p.LegalOwnerPubID.String, ctx, txn)
+      if restErr != nil {
+        return ``, nil, restErr
+      }
+      params = append(params, legalOwnerId)
+    } else if field.jsonName == `supportPhone` {
+      params = append(params, p.storedPhone())
+    } else {
+      params = append(params, *field.value(p))
+    }
+  }
+  params = append(params, id)
+
+  return `UPDATE products SET ` + strings.Join(setBits, `, `) + ` WHERE id=?`, params, nil
+}
+
+// The legal owner must be a user, per the 'products_ref_users' constraint.
+const legalOwnerIDStatement = `SELECT lo.id FROM entities lo JOIN users u ON lo.id=u.id WHERE lo.pub_id=?`
+
+// resolveLegalOwnerInTxn retrieves the internal ID of the legal owner. A
+// 'legalOwnerPubID' which does not identify a user results in a
+// ValidationError.
+func resolveLegalOwnerInTxn(legalOwnerPubID string, ctx context.Context, txn *sql.Tx) (int64, rest.RestError) {
+  var id int64
+  if err := txn.QueryRowContext(ctx, sqlDialect().Rebind(legalOwnerIDStatement), legalOwnerPubID).Scan(&id); err == sql.ErrNoRows {
+    return 0, legalOwnerNotFoundError(legalOwnerPubID)
+  } else if err != nil {
+    return 0, ClassifySQLError("Could not verify legal owner.", err)
   }
-  if getProductQuery, err = db.Prepare(getProductStatement); err != nil {
-    log.Fatalf("mysql: prepare get product stmt:\n%v\nQuery: %s", err, getProductStatement)
+  return id, nil
+}
+
+// touchProductInTxn updates the Product 'LastUpdated' value.
+func touchProductInTxn(id int64, ctx context.Context, txn *sql.Tx) rest.RestError {
+  if _, err := txn.StmtContext(ctx, currentStatements().touchProduct).ExecContext(ctx, id); err != nil {
+    return ClassifySQLError("Could not update product record.", err)
+  }
+  return nil
+}
+
+// The dialect lock clause is appended.
+const lockProductStatement = `SELECT p.id, e.last_updated FROM products p JOIN entities e ON p.id=e.id WHERE e.pub_id=? AND NOT p.archived`
+
+// checkProductVersionInTxn locks the Product record for the remainder of the
+// transaction and verifies that 'lastUpdated' matches the stored value. The
+// internal ID of the Product is returned.
+func checkProductVersionInTxn(pubId string, lastUpdated nulls.Int64, ctx context.Context, txn *sql.Tx) (int64, rest.RestError) {
+  if !lastUpdated.Valid {
+    return 0, preconditionRequiredError(fmt.Sprintf(`Update of product '%s' must specify 'lastUpdated'.`, pubId), nil)
+  }
+
+  id, current, restErr := lockProductInTxn(pubId, ctx, txn)
+  if restErr != nil {
+    return 0, restErr
+  }
+  if current != lastUpdated {
+    return 0, conflictError(fmt.Sprintf(`Product '%s' has been modified (last updated %d, expected %d); re-retrieve and try again.`, pubId, current.Int64, lastUpdated.Int64), nil)
+  }
+
+  return id, nil
+}
+
+// lockProductInTxn locks the Product record for the remainder of the
+// transaction, returning the internal ID and 'LastUpdated' value.
+func lockProductInTxn(pubId string, ctx context.Context, txn *sql.Tx) (int64, nulls.Int64, rest.RestError) {
+  var id int64
+  var current nulls.Int64
+  if err := txn.StmtContext(ctx, currentStatements().lockProduct).QueryRowContext(ctx, pubId).Scan(&id, &current); err == sql.ErrNoRows {
+    return 0, current, rest.NotFoundError(fmt.Sprintf(`Product '%s' not found.`, pubId), nil)
+  } else if err != nil {
+    return 0, current, ClassifySQLError("Could not verify product version.", err)
+  }
+  return id, current, nil
+}
+
+// DeleteProduct archives the Product identified by the public ID. Archived
+// Products are hidden from GetProduct and ListProducts, but are retained and
+// may be brought back with RestoreProduct. Attempting to delete a non-existent
+// or already archived Product results in a rest.NotFoundError.
+func DeleteProduct(pubId string, ctx context.Context) (*Product, rest.RestError) {
+  var archivedP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    archivedP, restErr = DeleteProductInTxn(pubId, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
+  }
+  return archivedP, nil
+}
+
+// DeleteProductInTxn archives a Product within an existing transaction. See
+// DeleteProduct.
+func DeleteProductInTxn(pubId string, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  product, restErr := GetProductInTxn(pubId, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
   }
-  if getProductByIdQuery, err = db.Prepare(getProductByIdStatement); err != nil {
-    log.Fatalf("mysql: prepare get product by ID stmt:\n%v\n%s", err, getProductByIdStatement)
+
+  return setProductArchivedInTxn(product, true, ctx, txn)
+}
+
+// RestoreProduct un-archives the Product identified by the public ID.
+// Restoring a Product which is not archived has no effect. Attempting to
+// restore a non-existent Product results in a rest.NotFoundError.
+func RestoreProduct(pubId string, ctx context.Context) (*Product, rest.RestError) {
+  var restoredP *Product
+  restErr := WithTxn(ctx, func(txn *sql.Tx) (restErr rest.RestError) {
+    restoredP, restErr = RestoreProductInTxn(pubId, ctx, txn)
+    return
+  })
+  if restErr != nil {
+    return nil, restErr
   }
-  if updateProductQuery, err = db.Prepare(updateProductStatement); err != nil {
-    log.Fatalf("mysql: prepare update product stmt:\n%v\n%s", err, updateProductStatement)
+  return restoredP, nil
+}
+
+// RestoreProductInTxn un-archives a Product within an existing transaction.
+// See RestoreProduct.
+func RestoreProductInTxn(pubId string, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  product, restErr := GetProductIncludeArchivedInTxn(pubId, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
   }
+
+  return setProductArchivedInTxn(product, false, ctx, txn)
 }
+
+func setProductArchivedInTxn(p *Product, archived bool, ctx context.Context, txn *sql.Tx) (*Product, rest.RestError) {
+  if _, err := txn.StmtContext(ctx, currentStatements().archiveProduct).ExecContext(ctx, archived, p.Id); err != nil {
+    return nil, ClassifySQLError("Could not update product archive status.", err)
+  }
+  if restErr := touchProductInTxn(p.Id.Int64, ctx, txn); restErr != nil {
+    return nil, restErr
+  }
+
+  newProduct, restErr := GetProductIncludeArchivedInTxn(p.PubId.String, ctx, txn)
+  if restErr != nil {
+    return nil, restErr
+  }
+  changeDesc := nulls.NewString(`Restored.`)
+  if archived {
+    changeDesc = nulls.NewString(`Archived.`)
+  }
+  if restErr := recordRevisionInTxn(newProduct, changeDesc, ctx, txn); restErr != nil {
+    return nil, restErr
+  }
+
+  return newProduct, nil
+}
+
+const archiveProductStatement = `UPDATE products SET archived=? WHERE id=?`
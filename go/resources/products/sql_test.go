@@ -1,9 +1,19 @@
 package products_test
 
 import (
+  "bytes"
   "context"
+  "database/sql"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
   "os"
+  "path/filepath"
+  "strings"
   "testing"
+  "time"
 
   // the package we're testing
   . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
@@ -11,32 +21,128 @@ import (
   "github.com/Liquid-Labs/catalyst-core-api/go/resources/locations"
   "github.com/Liquid-Labs/catalyst-core-api/go/resources/users"
   "github.com/Liquid-Labs/go-api/sqldb"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/go-sql-driver/mysql"
+  "github.com/gorilla/mux"
   "github.com/stretchr/testify/assert"
   "github.com/stretchr/testify/require"
 )
 
+// productDBTests are run against each supported database.
+var productDBTests = []struct{
+  name string
+  test func(*testing.T)
+}{
+  {`ProductGet`, testProductGet},
+  {`ProductList`, testProductList},
+  {`ProductListPage`, testProductListPage},
+  {`ProductListFiltered`, testProductListFiltered},
+  {`ProductFacets`, testProductFacets},
+  {`ProductSuggest`, testProductSuggest},
+  {`ProductFullTextSearch`, testProductFullTextSearch},
+  {`ProductCreate`, testProductCreate},
+  {`ProductUnknownLegalOwner`, testProductUnknownLegalOwner},
+  {`ProductUpdate`, testProductUpdate},
+  {`ProductUpdateConflict`, testProductUpdateConflict},
+  {`ProductUpdateSameSecond`, testProductUpdateSameSecond},
+  {`ProductUpdatePhone`, testProductUpdatePhone},
+  {`ProductPatch`, testProductPatch},
+  {`ProductPatchAPI`, testProductPatchAPI},
+  {`ProductGetInTxn`, testProductGetInTxn},
+  {`ProductCreateInTxn`, testProductCreateInTxn},
+  {`ProductUpdateInTxn`, testProductUpdateInTxn},
+  {`ProductWithTxn`, testProductWithTxn},
+  {`ProductCanceled`, testProductCanceled},
+  {`ProductDeleteAndRestore`, testProductDeleteAndRestore},
+  {`ProductRevisions`, testProductRevisions},
+  {`ProductAPIActor`, testProductAPIActor},
+  {`ProductRevert`, testProductRevert},
+  {`ProductBulkSave`, testProductBulkSave},
+  {`ProductImportCSV`, testProductImportCSV},
+  {`ProductExport`, testProductExport},
+}
+
+// testDB is the database under test.
+var testDB *sql.DB
+
+func runProductDBTests(t *testing.T) {
+  if widgetProduct == nil {
+    t.Error(`Product struct not define; can't continue. This probbaly indicates a setup failure in 'model_test.go'.`)
+    return
+  }
+  for _, dbTest := range productDBTests {
+    t.Run(dbTest.name, dbTest.test)
+  }
+}
+
 func TestProductsDBIntegration(t *testing.T) {
   if os.Getenv(`SKIP_INTEGRATION`) == `true` {
     t.Skip()
   }
 
-  if widgetProduct == nil {
-    t.Error(`Product struct not define; can't continue. This probbaly indicates a setup failure in 'model_test.go'.`)
-  } else {
-    if t.Run(`ProductsDBSetup`, testProductDBSetup) {
-      if sqldb.DB == nil { // test was skipped, but we still need to setup
-        setupDB()
-      }
-      t.Run(`ProductGet`, testProductGet)
-      t.Run(`ProductCreate`, testProductCreate)
-      t.Run(`ProductUpdate`, testProductUpdate)
-      t.Run(`ProductGetInTxn`, testProductGetInTxn)
-      t.Run(`ProductCreateInTxn`, testProductCreateInTxn)
-      t.Run(`ProductUpdateInTxn`, testProductUpdateInTxn)
+  if t.Run(`ProductsDBSetup`, testProductDBSetup) {
+    if sqldb.DB == nil { // test was skipped, but we still need to setup
+      setupDB()
     }
+    testDB = sqldb.DB
+    runProductDBTests(t)
   }
 }
 
+// TestProductsSQLiteIntegration runs the database tests against a new SQLite
+// database, and so doesn't require any external setup.
+func TestProductsSQLiteIntegration(t *testing.T) {
+  db, err := OpenSQLite(filepath.Join(t.TempDir(), `products.db`))
+  require.NoError(t, err, `Unexpected error opening SQLite database.`)
+  defer db.Close()
+
+  testData, err := ioutil.ReadFile(`../../../data/sql-sqlite/test/test-data.sql`)
+  require.NoError(t, err, `Unexpected error reading test data.`)
+  _, err = db.Exec(string(testData))
+  require.NoError(t, err, `Unexpected error loading test data.`)
+
+  testDB = db
+  runProductDBTests(t)
+  t.Run(`SQLiteErrors`, testSQLiteErrors)
+}
+
+func testSQLiteErrors(t *testing.T) {
+  _, err := testDB.Exec(`INSERT INTO products (id, legal_owner, summary, support_email) VALUES (1, 1, 'x', 'x@test.com')`)
+  restErr := ClassifySQLError(`Test operation.`, err)
+  require.IsType(t, ValidationError{}, restErr, `Unexpected error type for '%s'.`, err)
+  assert.Contains(t, restErr.(ValidationError).Fields, `displayName`, `Expected display name field error.`)
+
+  _, err = testDB.Exec(`INSERT INTO entities (pub_id) VALUES (?)`, someProductID)
+  assert.Equal(t, http.StatusConflict, ClassifySQLError(`Test operation.`, err).Code(), `Unexpected code for '%s'.`, err)
+}
+
+// TestProductsPostgresIntegration runs the database tests against the
+// PostgreSQL database identified by 'PRODUCTS_TEST_POSTGRES_DSN', which must
+// have only the 'data/sql-postgres' schema. The products schema is migrated
+// and the test data loaded by the test.
+func TestProductsPostgresIntegration(t *testing.T) {
+  dsn := os.Getenv(`PRODUCTS_TEST_POSTGRES_DSN`)
+  if dsn == `` || os.Getenv(`SKIP_INTEGRATION`) == `true` {
+    t.Skip()
+  }
+
+  db, err := OpenPostgres(dsn)
+  require.NoError(t, err, `Unexpected error opening PostgreSQL database.`)
+  defer db.Close()
+
+  testData, err := ioutil.ReadFile(`../../../data/sql-postgres/test/test-data.sql`)
+  require.NoError(t, err, `Unexpected error reading test data.`)
+  _, err = db.Exec(string(testData))
+  require.NoError(t, err, `Unexpected error loading test data.`)
+
+  testDB = db
+  runProductDBTests(t)
+}
+
+// maxTestItems exceeds the number of test Products.
+const maxTestItems = 100
+
 const someProductID=`D929BEE3-8034-40A9-B33E-E1A28507EE68`
 
 func setupDB() {
@@ -61,6 +167,174 @@ func testProductGet(t *testing.T) {
   assert.Equal(t, someProductID, product.PubId.String, `Unexpected public id.`)
 }
 
+func testProductList(t *testing.T) {
+  searchParams := &rest.SearchParams{
+    Terms: []string{`Blog`},
+    PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10},
+  }
+  products, err := ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, err, `Unexpected error listing Products.`)
+  require.Len(t, products, 1, `Unexpected number of Products found.`)
+  assert.Equal(t, `Blog`, products[0].DisplayName.String, `Unexpected display name.`)
+  assert.Equal(t, int64(1), searchParams.PageInfo.TotalItemCount, `Unexpected total item count.`)
+
+  searchParams = &rest.SearchParams{
+    Sort: `name-desc`,
+    PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10},
+  }
+  products, err = ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, err, `Unexpected error listing Products.`)
+  require.True(t, len(products) >= 2, `Expected at least two Products.`)
+  assert.True(t, products[0].DisplayName.String >= products[1].DisplayName.String, `Products not sorted descending.`)
+
+  searchParams.Sort = `bad-sort`
+  _, err = ListProducts(searchParams, false, nil, context.Background())
+  assert.Error(t, err, `Expected error on unknown sort.`)
+}
+
+func testProductListPage(t *testing.T) {
+  ctx := context.Background()
+  searchParams := &rest.SearchParams{Sort: `name-desc`, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: maxTestItems}}
+  expected, restErr := ListProducts(searchParams, false, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  require.True(t, len(expected) >= 2, `Expected at least two Products.`)
+
+  searchParams.PageInfo = &rest.PageInfo{ItemsPerPage: 1}
+  walked := make([]*Product, 0)
+  products := NewProductIterator(SQLStore{}, searchParams, false, nil, ctx)
+  for products.Next() {
+    require.Len(t, products.Page(), 1, `Unexpected page size.`)
+    walked = append(walked, products.Page()...)
+  }
+  require.NoError(t, products.Err(), `Unexpected error iterating Products.`)
+  assert.Equal(t, expected, walked, `Cursor paging does not match offset listing.`)
+
+  page, restErr := ListProductsPage(searchParams, false, nil, CursorAfter(expected[0], `name-desc`), ctx)
+  require.NoError(t, restErr, `Unexpected error listing page.`)
+  assert.Equal(t, expected[1:2], page.Products, `Unexpected page after cursor.`)
+  page, restErr = ListProductsPage(searchParams, false, nil, page.Prev, ctx)
+  require.NoError(t, restErr, `Unexpected error listing previous page.`)
+  assert.Equal(t, expected[0:1], page.Products, `Unexpected previous page.`)
+  assert.Empty(t, page.Prev, `Unexpected 'prev' cursor on first page.`)
+
+  exported := make([]*Product, 0)
+  restErr = ExportProducts(searchParams, false, nil, CursorAfter(expected[0], `name-desc`), func(p *Product) error {
+    exported = append(exported, p)
+    return nil
+  }, ctx)
+  require.NoError(t, restErr, `Unexpected error exporting Products.`)
+  assert.Equal(t, expected[1:], exported, `Unexpected Products exported after cursor.`)
+}
+
+func testProductListFiltered(t *testing.T) {
+  list := func(filters *ProductFilters) []string {
+    searchParams := &rest.SearchParams{Terms: []string{`B`}, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: maxTestItems}}
+    products, restErr := ListProducts(searchParams, false, filters, context.Background())
+    require.NoError(t, restErr, `Unexpected error listing Products.`)
+    names := make([]string, 0, len(products))
+    for _, p := range products {
+      names = append(names, p.DisplayName.String)
+    }
+    return names
+  }
+
+  assert.Equal(t, []string{`Bauble`}, list(&ProductFilters{Ontologies: []string{`TANGIBLE GOOD`, `DIGITAL GOOD`}}), `Unexpected Products by ontology.`)
+  assert.Equal(t, []string{`Bauble`, `Blog`}, list(&ProductFilters{LegalOwnerPubID: `4c2b3954-8d7f-48ba-b720-3b0f15f91ba9`}), `Unexpected Products by legal owner.`)
+  assert.Empty(t, list(&ProductFilters{LegalOwnerPubID: someProductID}), `Unexpected Products for non-owner.`)
+  assert.Equal(t, []string{`Bauble`, `Blog`}, list(&ProductFilters{Present: map[string]bool{`repoURL`: true, `issuesURL`: false}}), `Unexpected Products by presence.`)
+  assert.Empty(t, list(&ProductFilters{Present: map[string]bool{`logoURL`: false}}), `Unexpected Products without logo.`)
+  assert.Equal(t, []string{`Blog`}, list(&ProductFilters{SupportEmailDomain: `FOO.com`, Ontologies: []string{`SOFTWARE SERVICE`}}), `Unexpected Products by email domain.`)
+  assert.Empty(t, list(&ProductFilters{SupportEmailDomain: `oo.com`}), `Unexpected Products by partial email domain.`)
+  assert.Equal(t, []string{`Bauble`, `Blog`}, list(&ProductFilters{UpdatedSince: 1}), `Unexpected Products updated since.`)
+  assert.Empty(t, list(&ProductFilters{UpdatedSince: 1 << 40}), `Unexpected Products updated in the future.`)
+
+  searchParams := &rest.SearchParams{PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10}}
+  _, restErr := ListProducts(searchParams, false, &ProductFilters{Ontologies: []string{`x') OR ('1'='1`}}, context.Background())
+  require.Error(t, restErr, `Unexpected success with invalid ontology.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected error code.`)
+}
+
+func testProductFacets(t *testing.T) {
+  searchParams := &rest.SearchParams{PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 1}}
+  facets, restErr := ListProductFacets(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Equal(t, []*OntologyFacet{{`SOFTWARE SERVICE`, 1}, {`TANGIBLE GOOD`, 1}}, facets.Ontology, `Unexpected ontology facets.`)
+  require.Len(t, facets.LegalOwner, 1, `Unexpected legal owner facets.`)
+  owner := facets.LegalOwner[0]
+  assert.Equal(t, `4C2B3954-8D7F-48BA-B720-3B0F15F91BA9`, owner.PubID, `Unexpected legal owner.`)
+  assert.Equal(t, `55-5555555`, owner.LegalID.String, `Unexpected legal owner legal ID.`)
+  assert.Equal(t, `EIN`, owner.LegalIDType.String, `Unexpected legal owner legal ID type.`)
+  assert.Equal(t, int64(2), owner.Count, `Unexpected legal owner count.`)
+  assert.Equal(t, PresenceFacet{Present: 2, Absent: 0}, facets.HasRepoURL, `Unexpected repo facet.`)
+  assert.Equal(t, PresenceFacet{Present: 0, Absent: 2}, facets.HasIssuesURL, `Unexpected issues facet.`)
+
+  searchParams.Terms = []string{`wall`}
+  facets, restErr = ListProductFacets(searchParams, false, &ProductFilters{Ontologies: []string{`TANGIBLE GOOD`}}, context.Background())
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Equal(t, []*OntologyFacet{{`TANGIBLE GOOD`, 1}}, facets.Ontology, `Unexpected filtered ontology facets.`)
+  assert.Equal(t, PresenceFacet{Present: 1, Absent: 0}, facets.HasRepoURL, `Unexpected filtered repo facet.`)
+
+  searchParams.Terms = []string{`nothing like it`}
+  facets, restErr = ListProductFacets(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error counting facets.`)
+  assert.Empty(t, facets.Ontology, `Unexpected ontology facets without matches.`)
+  assert.Empty(t, facets.LegalOwner, `Unexpected legal owner facets without matches.`)
+  assert.Equal(t, PresenceFacet{}, facets.HasRepoURL, `Unexpected repo facet without matches.`)
+}
+
+func testProductSuggest(t *testing.T) {
+  suggest := func(query string) []*ProductSuggestion {
+    suggestions, restErr := SuggestProducts(query, 10, false, nil, context.Background())
+    require.NoError(t, restErr, `Unexpected error suggesting Products.`)
+    return suggestions
+  }
+
+  suggestions := suggest(`Buable`)
+  require.Len(t, suggestions, 1, `Unexpected number of suggestions.`)
+  assert.Equal(t, `Bauble`, suggestions[0].DisplayName, `Unexpected suggestion.`)
+  assert.Equal(t, `D929BEE3-8034-40A9-B33E-E1A28507EE68`, suggestions[0].PubID, `Unexpected suggestion public ID.`)
+  // The closer the completion, the better.
+  assert.Equal(t, []string{`Blog`, `Bauble`}, suggestionNames(suggest(`b`)), `Unexpected completions.`)
+  assert.Equal(t, []string{`Blog`}, suggestionNames(suggest(`blgo`)), `Unexpected misspelling suggestion.`)
+  assert.Empty(t, suggest(`proudcts`), `Unexpected suggestions for unrelated name.`)
+  assert.Empty(t, suggest(`...`), `Unexpected suggestions for query without words.`)
+}
+
+func testProductFullTextSearch(t *testing.T) {
+  ctx := context.Background()
+  list := func(term string, mode string) []*Product {
+    searchParams := &rest.SearchParams{Terms: []string{term}, Sort: `relevance`, PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: maxTestItems}}
+    products, restErr := ListProducts(searchParams, false, &ProductFilters{SearchMode: mode}, ctx)
+    require.NoError(t, restErr, `Unexpected error searching Products.`)
+    return products
+  }
+
+  products := list(`articles`, SearchNatural)
+  require.Len(t, products, 1, `Unexpected number of Products found.`)
+  assert.Equal(t, `Blog`, products[0].DisplayName.String, `Unexpected Product found.`)
+  assert.Equal(t, `Online <mark>articles</mark>.`, products[0].Snippet, `Unexpected snippet.`)
+  assert.Empty(t, list(`+wall -thing`, SearchBoolean), `Unexpected Products with excluded word.`)
+
+  // The index follows changes to Products.
+  product := widgetProduct.Clone()
+  product.PubId = nulls.NewNullString()
+  product.SetDisplayName(`Wall Hanging`)
+  product.SetSummary(`Something for your wall; wall art.`)
+  product, restErr := CreateProduct(product, ctx)
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  products = list(`wall`, SearchNatural)
+  require.Len(t, products, 2, `Unexpected number of Products found.`)
+  assert.Equal(t, `Wall Hanging`, products[0].DisplayName.String, `Unexpected Product ranked first.`)
+  assert.Equal(t, `Bauble`, products[1].DisplayName.String, `Unexpected Product ranked second.`)
+
+  product.SetSummary(`Something for your room.`)
+  _, restErr = UpdateProduct(product, ctx)
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+  assert.Len(t, list(`room`, SearchNatural), 1, `Updated Product not found.`)
+  _, restErr = DeleteProduct(product.PubId.String, ctx)
+  require.NoError(t, restErr, `Unexpected error deleting Product.`)
+}
+
 func testProductCreate(t *testing.T) {
   product, err := CreateProduct(widgetProduct, context.Background())
   require.NoError(t, err, `Unexpected error creating Product.`)
@@ -73,6 +347,26 @@ func testProductCreate(t *testing.T) {
   assert.NotEmpty(t, product.PubId, `Unexpected empty public id.`)
 }
 
+func testProductUnknownLegalOwner(t *testing.T) {
+  for _, ownerID := range []string{`00000000-0000-0000-0000-000000000000`, someProductID /* an entity, but not a user */} {
+    product := widgetProduct.Clone()
+    product.PubId = nulls.NewNullString()
+    product.SetLegalOwnerPubID(ownerID)
+    _, err := CreateProduct(product, context.Background())
+    require.Error(t, err, `Unexpected success creating Product with unknown legal owner.`)
+    assert.Equal(t, http.StatusUnprocessableEntity, err.Code(), `Unexpected error code.`)
+    require.IsType(t, ValidationError{}, err, `Unexpected error type.`)
+    assert.Contains(t, err.(ValidationError).Fields, `legalOwnerPubID`, `Expected legal owner field error.`)
+
+    existing, err := GetProduct(someProductID, context.Background())
+    require.NoError(t, err, `Unexpected error getting Product.`)
+    existing.SetLegalOwnerPubID(ownerID)
+    _, err = UpdateProduct(existing, context.Background())
+    require.Error(t, err, `Unexpected success updating Product with unknown legal owner.`)
+    assert.Equal(t, http.StatusUnprocessableEntity, err.Code(), `Unexpected error code.`)
+  }
+}
+
 func testProductUpdate(t *testing.T) {
   someOtherProduct, err := GetProduct(someProductID, context.Background())
   require.NoError(t, err, `Unexpected error getting Product.`)
@@ -92,10 +386,121 @@ func testProductUpdate(t *testing.T) {
   assert.NotEmpty(t, product.PubId, `Unexpected empty public id.`)
 }
 
+// The phone number is normalized on update, as on insert.
+func testProductUpdatePhone(t *testing.T) {
+  product, restErr := CreateProduct(widgetProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  product.SetSupportPhone(`(555) 555-0004`)
+  updated, restErr := UpdateProduct(product, context.Background())
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+  assert.Equal(t, `555-555-0004`, updated.SupportPhone.String, `Unexpected phone format on update.`)
+
+  patched, fields, restErr := ApplyMergePatch(updated, []byte(`{"supportPhone": "+1 555 555 0005"}`))
+  require.NoError(t, restErr, `Unexpected error applying patch.`)
+  patched, restErr = PatchProduct(patched, fields, context.Background())
+  require.NoError(t, restErr, `Unexpected error patching Product.`)
+  assert.Equal(t, `15555550005`, patched.SupportPhone.String, `Unexpected phone format on patch.`)
+}
+
+func testProductUpdateConflict(t *testing.T) {
+  staleProduct, restErr := GetProduct(someProductID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  freshProduct := staleProduct.Clone()
+  freshProduct.SetSummary(`A thing for any wall.`)
+  _, restErr = UpdateProduct(freshProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+
+  staleProduct.SetSummary(`A thing for some wall.`)
+  product, restErr := UpdateProduct(staleProduct, context.Background())
+  assert.Nil(t, product, `Unexpected Product on stale update.`)
+  require.Error(t, restErr, `Unexpected non-error on stale update.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on stale update.`)
+
+  staleProduct.LastUpdated = nulls.NewNullInt64()
+  _, restErr = UpdateProduct(staleProduct, context.Background())
+  require.Error(t, restErr, `Unexpected non-error on unconditional update.`)
+  assert.Equal(t, http.StatusPreconditionRequired, restErr.Code(), `Unexpected error code on unconditional update.`)
+}
+
+// testProductUpdateSameSecond checks that each update yields a new version,
+// even when the updates fall within the same second.
+func testProductUpdateSameSecond(t *testing.T) {
+  ctx := context.Background()
+  product, restErr := GetProduct(someProductID, ctx)
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  versions := []int64{product.LastUpdated.Int64}
+  var stale *Product
+  for _, summary := range []string{`A thing for one wall.`, `A thing for two walls.`} {
+    stale = product.Clone()
+    product.SetSummary(summary)
+    product, restErr = UpdateProduct(product, ctx)
+    require.NoError(t, restErr, `Unexpected error updating Product.`)
+    versions = append(versions, product.LastUpdated.Int64)
+  }
+  assert.True(t, versions[0] < versions[1] && versions[1] < versions[2], `Versions %v do not increase.`, versions)
+
+  // 'stale' holds the version of the first update.
+  stale.SetSummary(`A thing for no wall.`)
+  _, restErr = UpdateProduct(stale, ctx)
+  require.Error(t, restErr, `Unexpected success on stale update.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on stale update.`)
+}
+
+func testProductPatch(t *testing.T) {
+  orig, restErr := GetProduct(someProductID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  patched, fields, restErr := ApplyMergePatch(orig, []byte(`{"supportPhone": "555-555-0002"}`))
+  require.NoError(t, restErr, `Unexpected error applying patch.`)
+  product, restErr := PatchProduct(patched, fields, context.Background())
+  require.NoError(t, restErr, `Unexpected error patching Product.`)
+  assert.Equal(t, `555-555-0002`, product.SupportPhone.String, `Unexpected phone.`)
+  assert.Equal(t, orig.DisplayName, product.DisplayName, `Unexpected display name.`)
+  assert.Equal(t, orig.Summary, product.Summary, `Unexpected summary.`)
+
+  _, restErr = PatchProduct(patched, fields, context.Background())
+  require.Error(t, restErr, `Unexpected non-error on stale patch.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on stale patch.`)
+}
+
+func testProductPatchAPI(t *testing.T) {
+  defer AuthenticateAs(`api-tester`)()
+  router := mux.NewRouter()
+  InitAPI(router)
+  product, restErr := CreateProduct(widgetProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  path := `/products/` + product.PubId.String + `/`
+  patch := func(contentType string, ifMatch string, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(`PATCH`, path, strings.NewReader(body))
+    req.Header.Set(`Content-Type`, contentType)
+    if ifMatch != `` {
+      req.Header.Set(`If-Match`, ifMatch)
+    }
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+  }
+
+  w := patch(MergePatchContentType, ``, `{"summary": "An unversioned dodad."}`)
+  assert.Equal(t, http.StatusPreconditionRequired, w.Code, `Unexpected status for unversioned patch: %s`, w.Body.String())
+
+  w = patch(MergePatchContentType, ``, fmt.Sprintf(`{"summary": "A versioned dodad.", "lastUpdated": %d}`, product.LastUpdated.Int64))
+  require.Equal(t, http.StatusOK, w.Code, `Unexpected status for versioned patch: %s`, w.Body.String())
+  etag := w.Header().Get(`ETag`)
+
+  w = patch(JSONPatchContentType, ``, fmt.Sprintf(`[{"op": "test", "path": "/lastUpdated", "value": %d}, {"op": "replace", "path": "/summary", "value": "A stale dodad."}]`, product.LastUpdated.Int64))
+  assert.Equal(t, http.StatusConflict, w.Code, `Unexpected status for stale patch: %s`, w.Body.String())
+
+  w = patch(JSONPatchContentType, etag, `[{"op": "replace", "path": "/summary", "value": "A matched dodad."}]`)
+  require.Equal(t, http.StatusOK, w.Code, `Unexpected status for 'If-Match' patch: %s`, w.Body.String())
+  patched, restErr := GetProduct(product.PubId.String, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  assert.Equal(t, `A matched dodad.`, patched.Summary.String, `Unexpected summary.`)
+}
+
 func testProductGetInTxn(t *testing.T) {
   someOtherProduct, restErr := GetProduct(someProductID, context.Background())
   assert.NoError(t, restErr, `Unexpected error getting product.`)
-  txn, _ := sqldb.DB.Begin()
+  txn, _ := testDB.Begin()
   orig := someOtherProduct.Clone()
   // if we get in a txn, we should see the changes
   someOtherProduct.SetSupportPhone(`555-555-0003`)
@@ -114,7 +519,7 @@ func testProductGetInTxn(t *testing.T) {
 func testProductCreateInTxn(t *testing.T) {
   yetAnotherProduct := widgetProduct.Clone()
   yetAnotherProduct.SetDisplayName(`Bauble 3.0`)
-  txn, _ := sqldb.DB.Begin()
+  txn, _ := testDB.Begin()
   txnProduct, restErr := CreateProductInTxn(yetAnotherProduct, context.Background(), txn)
   assert.NoError(t, restErr, `Unexpected error creating product in txn.`)
   noProduct, restErr := GetProduct(txnProduct.PubId.String, context.Background())
@@ -126,6 +531,270 @@ func testProductCreateInTxn(t *testing.T) {
 }
 
 func testProductUpdateInTxn(t *testing.T) {
-  /*txn, err := sqldb.DB.Begin()
+  /*txn, err := testDB.Begin()
   assert.NoError(t, err, `Unexpected error opening transaction.`)*/
 }
+
+func testProductWithTxn(t *testing.T) {
+  var txnProduct *Product
+  failure := rest.BadRequestError(`Abort.`, nil)
+  restErr := WithTxn(context.Background(), func(txn *sql.Tx) rest.RestError {
+    newProduct := widgetProduct.Clone()
+    newProduct.SetDisplayName(`Rolled Back Widget`)
+    var restErr rest.RestError
+    if txnProduct, restErr = CreateProductInTxn(newProduct, context.Background(), txn); restErr != nil {
+      return restErr
+    }
+    return failure
+  })
+  assert.Equal(t, failure, restErr, `Unexpected error from transaction.`)
+  require.NotNil(t, txnProduct, `Product not created in txn.`)
+  noProduct, restErr := GetProduct(txnProduct.PubId.String, context.Background())
+  assert.Nil(t, noProduct, `Unexpected retrieval of rolled back Product.`)
+  assert.Error(t, restErr, `Unexpected non-error retrieving rolled back Product.`)
+
+  attempts := 0
+  restErr = WithTxn(context.Background(), func(txn *sql.Tx) rest.RestError {
+    attempts += 1
+    if attempts < 2 {
+      return ClassifySQLError(`Test deadlock.`, &mysql.MySQLError{1213, `Deadlock found when trying to get lock`})
+    }
+    return nil
+  })
+  assert.NoError(t, restErr, `Unexpected error after retry.`)
+  assert.Equal(t, 2, attempts, `Unexpected number of attempts.`)
+}
+
+func testProductCanceled(t *testing.T) {
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  _, restErr := GetProduct(someProductID, ctx)
+  require.Error(t, restErr, `Unexpected success with canceled context.`)
+  assert.Equal(t, StatusClientClosedRequest, restErr.Code(), `Unexpected error code.`)
+
+  product, restErr := GetProduct(someProductID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  _, restErr = UpdateProduct(product, ctx)
+  require.Error(t, restErr, `Unexpected success with canceled context.`)
+  assert.Equal(t, StatusClientClosedRequest, restErr.Code(), `Unexpected error code.`)
+
+  defer func(timeout time.Duration) { QueryTimeout = timeout }(QueryTimeout)
+  QueryTimeout = time.Nanosecond
+  _, restErr = GetProduct(someProductID, context.Background())
+  require.Error(t, restErr, `Unexpected success with expired timeout.`)
+  assert.Equal(t, http.StatusGatewayTimeout, restErr.Code(), `Unexpected error code.`)
+}
+
+func testProductDeleteAndRestore(t *testing.T) {
+  doomedProduct := widgetProduct.Clone()
+  doomedProduct.SetDisplayName(`Doomed Widget`)
+  product, restErr := CreateProduct(doomedProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  pubID := product.PubId.String
+
+  archived, restErr := DeleteProduct(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error deleting Product.`)
+  assert.True(t, archived.Archived.Bool, `Product not marked archived.`)
+
+  noProduct, restErr := GetProduct(pubID, context.Background())
+  assert.Nil(t, noProduct, `Unexpected retrieval of archived Product.`)
+  assert.Error(t, restErr, `Unexpected non-error while retrieving archived Product.`)
+
+  _, restErr = DeleteProduct(pubID, context.Background())
+  assert.Error(t, restErr, `Unexpected non-error while deleting archived Product.`)
+
+  searchParams := &rest.SearchParams{
+    Terms: []string{`Doomed Widget`},
+    PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10},
+  }
+  products, restErr := ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  assert.Len(t, products, 0, `Archived Product unexpectedly listed.`)
+  products, restErr = ListProducts(searchParams, true, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  assert.Len(t, products, 1, `Archived Product not listed with 'includeArchived'.`)
+
+  stillThere, restErr := GetProductIncludeArchived(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error retrieving archived Product.`)
+  assert.True(t, stillThere.Archived.Bool, `Product not marked archived.`)
+
+  restored, restErr := RestoreProduct(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error restoring Product.`)
+  assert.False(t, restored.Archived.Bool, `Product still marked archived.`)
+  _, restErr = GetProduct(pubID, context.Background())
+  assert.NoError(t, restErr, `Unexpected error retrieving restored Product.`)
+}
+
+func testProductRevisions(t *testing.T) {
+  ctx := ContextWithActor(context.Background(), `tester`)
+  product, restErr := CreateProduct(widgetProduct, ctx)
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  pubID := product.PubId.String
+  product.SetSummary(`An even better dodad.`)
+  product.SetChangeDesc(`Improved summary.`)
+  _, restErr = UpdateProduct(product, ctx)
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+
+  revisions, restErr := GetProductRevisions(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting revisions.`)
+  require.Len(t, revisions, 2, `Unexpected number of revisions.`)
+  assert.Equal(t, int64(1), revisions[0].Revision, `Unexpected first revision number.`)
+  assert.Equal(t, widgetProduct.ChangeDesc, revisions[0].ChangeDesc, `Unexpected first change description.`)
+  assert.Equal(t, widgetProduct.Summary, revisions[0].Product.Summary, `Unexpected first snapshot summary.`)
+  assert.Equal(t, `Improved summary.`, revisions[1].ChangeDesc.String, `Unexpected second change description.`)
+  assert.Equal(t, `An even better dodad.`, revisions[1].Product.Summary.String, `Unexpected second snapshot summary.`)
+  assert.Equal(t, `tester`, revisions[1].Actor.String, `Unexpected actor.`)
+
+  revision, restErr := GetProductRevision(pubID, 2, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting revision.`)
+  assert.Equal(t, *revisions[1], *revision, `Revision does not match history.`)
+  _, restErr = GetProductRevision(pubID, 3, context.Background())
+  assert.Error(t, restErr, `Unexpected non-error getting non-existent revision.`)
+}
+
+func testProductAPIActor(t *testing.T) {
+  defer AuthenticateAs(`api-tester`)()
+  router := mux.NewRouter()
+  InitAPI(router)
+
+  product, restErr := CreateProduct(widgetProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  pubID := product.PubId.String
+  product.SetSummary(`A dodad updated through the API.`)
+  body, err := json.Marshal(product)
+  require.NoError(t, err, `Unexpected error encoding Product.`)
+  w := httptest.NewRecorder()
+  router.ServeHTTP(w, httptest.NewRequest(`PUT`, `/products/` + pubID + `/`, bytes.NewReader(body)))
+  require.Equal(t, http.StatusOK, w.Code, `Unexpected status updating Product: %s`, w.Body.String())
+
+  revisions, restErr := GetProductRevisions(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting revisions.`)
+  require.Len(t, revisions, 2, `Unexpected number of revisions.`)
+  assert.False(t, revisions[0].Actor.Valid, `Unexpected actor without authentication.`)
+  assert.Equal(t, `api-tester`, revisions[1].Actor.String, `Authenticated user not recorded as actor.`)
+}
+
+func testProductRevert(t *testing.T) {
+  product, restErr := CreateProduct(widgetProduct, context.Background())
+  require.NoError(t, restErr, `Unexpected error creating Product.`)
+  pubID := product.PubId.String
+  product.SetDisplayName(`Wodget`)
+  product.SetSupportEmail(`wodget@test.com`)
+  product, restErr = UpdateProduct(product, context.Background())
+  require.NoError(t, restErr, `Unexpected error updating Product.`)
+
+  _, restErr = RevertProduct(pubID, 1, nulls.NewInt64(product.LastUpdated.Int64 - 1), nulls.NewNullString(), context.Background())
+  require.Error(t, restErr, `Unexpected non-error on stale revert.`)
+  assert.Equal(t, http.StatusConflict, restErr.Code(), `Unexpected error code on stale revert.`)
+
+  reverted, restErr := RevertProduct(pubID, 1, product.LastUpdated, nulls.NewNullString(), context.Background())
+  require.NoError(t, restErr, `Unexpected error reverting Product.`)
+  assert.Equal(t, widgetProduct.DisplayName, reverted.DisplayName, `Unexpected display name.`)
+  assert.Equal(t, widgetProduct.SupportEmail, reverted.SupportEmail, `Unexpected email.`)
+
+  revisions, restErr := GetProductRevisions(pubID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting revisions.`)
+  require.Len(t, revisions, 3, `Revert not recorded as a revision.`)
+  assert.Equal(t, `Reverted to revision 1.`, revisions[2].ChangeDesc.String, `Unexpected change description.`)
+}
+
+func testProductBulkSave(t *testing.T) {
+  existing, restErr := GetProduct(someProductID, context.Background())
+  require.NoError(t, restErr, `Unexpected error getting Product.`)
+  existing.SetSummary(`A bulk updated bauble.`)
+  newProduct := widgetProduct.Clone()
+  newProduct.PubId = nulls.NewNullString() // bulk items without pubId are created
+  newProduct.SetDisplayName(`Bulk Widget`)
+  badProduct := widgetProduct.Clone()
+  badProduct.PubId = nulls.NewNullString()
+  badProduct.SetOntology(`NOT AN ONTOLOGY`)
+
+  results, restErr := BulkSaveProducts([]*Product{newProduct, existing, badProduct, nil}, false, context.Background())
+  require.NoError(t, restErr, `Unexpected error on non-atomic bulk save.`)
+  require.Len(t, results, 4, `Unexpected number of results.`)
+  require.NotNil(t, results[0].Product, `Expected created Product.`)
+  assert.Equal(t, `Bulk Widget`, results[0].Product.DisplayName.String, `Unexpected display name.`)
+  require.NotNil(t, results[1].Product, `Expected updated Product.`)
+  assert.Equal(t, `A bulk updated bauble.`, results[1].Product.Summary.String, `Unexpected summary.`)
+  assert.Nil(t, results[2].Product, `Unexpected Product for bad item.`)
+  assert.NotNil(t, results[2].Error, `Expected error for bad item.`)
+  require.NotNil(t, results[3].Error, `Expected error for null item.`)
+  assert.Equal(t, http.StatusBadRequest, results[3].Error.Code, `Unexpected code for null item.`)
+
+  atomicProduct := widgetProduct.Clone()
+  atomicProduct.PubId = nulls.NewNullString()
+  atomicProduct.SetDisplayName(`Atomic Widget`)
+  results, restErr = BulkSaveProducts([]*Product{atomicProduct, nil}, true, context.Background())
+  require.Error(t, restErr, `Expected error on atomic bulk save with null item.`)
+  assert.Equal(t, http.StatusBadRequest, restErr.Code(), `Unexpected code for null item.`)
+  assert.Nil(t, results, `Unexpected results for rejected request.`)
+  results, restErr = BulkSaveProducts([]*Product{atomicProduct, badProduct}, true, context.Background())
+  assert.Error(t, restErr, `Expected error on failed atomic bulk save.`)
+  require.Len(t, results, 2, `Unexpected number of results.`)
+  assert.Equal(t, http.StatusFailedDependency, results[0].Error.Code, `Unexpected code for rolled back item.`)
+  searchParams := &rest.SearchParams{
+    Terms: []string{`Atomic Widget`},
+    PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10},
+  }
+  products, restErr := ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  assert.Len(t, products, 0, `Rolled back Product unexpectedly found.`)
+}
+
+const importCSV = `legalOwnerPubID,displayName,summary,supportEmail,ontology
+4C2B3954-8D7F-48BA-B720-3B0F15F91BA9,Imported Widget,An imported dodad.,import@test.com,TANGIBLE GOOD
+4C2B3954-8D7F-48BA-B720-3B0F15F91BA9,Imported Gadget,An imported gadget.,import@test.com,NOT AN ONTOLOGY
+00000000-0000-4000-8000-000000000000,Orphan Widget,An orphaned dodad.,import@test.com,TANGIBLE GOOD
+`
+
+func testProductImportCSV(t *testing.T) {
+  searchParams := &rest.SearchParams{
+    Terms: []string{`Imported Widget`},
+    PageInfo: &rest.PageInfo{PageIndex: 1, ItemsPerPage: 10},
+  }
+
+  report, restErr := ImportProductsCSV(strings.NewReader(importCSV), true, context.Background())
+  require.NoError(t, restErr, `Unexpected error on dry run import.`)
+  assert.Equal(t, 3, report.Rows, `Unexpected row count.`)
+  assert.Equal(t, 1, report.Created, `Unexpected created count.`)
+  assert.Equal(t, 2, report.Failed, `Unexpected failed count.`)
+  require.Len(t, report.Errors, 2, `Unexpected number of errors.`)
+  assert.Equal(t, 3, report.Errors[0].Row, `Unexpected error row.`)
+  assert.Equal(t, 4, report.Errors[1].Row, `Unexpected error row.`)
+  assert.Equal(t, `legalOwnerPubID`, report.Errors[1].Field, `Unexpected error field.`)
+  products, restErr := ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  assert.Len(t, products, 0, `Dry run import unexpectedly saved Product.`)
+
+  report, restErr = ImportProductsCSV(strings.NewReader(importCSV), false, context.Background())
+  require.NoError(t, restErr, `Unexpected error on import.`)
+  assert.Equal(t, 1, report.Created, `Unexpected created count.`)
+  products, restErr = ListProducts(searchParams, false, nil, context.Background())
+  require.NoError(t, restErr, `Unexpected error listing Products.`)
+  assert.Len(t, products, 1, `Imported Product not found.`)
+}
+
+func testProductExport(t *testing.T) {
+  searchParams := &rest.SearchParams{Sort: `name-asc`}
+  exported := make([]*Product, 0)
+  restErr := ExportProducts(searchParams, false, nil, ``, func(p *Product) error {
+    exported = append(exported, p)
+    return nil
+  }, context.Background())
+  require.NoError(t, restErr, `Unexpected error exporting Products.`)
+  require.True(t, len(exported) >= 2, `Expected at least two Products.`)
+  for i := 1; i < len(exported); i++ {
+    assert.True(t, exported[i - 1].DisplayName.String <= exported[i].DisplayName.String, `Products not sorted ascending.`)
+  }
+
+  var data strings.Builder
+  writer := NewProductCSVWriter(&data)
+  for _, p := range exported {
+    require.NoError(t, writer.Write(p), `Unexpected error writing CSV.`)
+  }
+  require.NoError(t, writer.Flush(), `Unexpected error flushing CSV.`)
+  report, restErr := ImportProductsCSV(strings.NewReader(data.String()), true, context.Background())
+  require.NoError(t, restErr, `Unexpected error importing export.`)
+  assert.Equal(t, len(exported), report.Updated, `Exported Products did not import as updates.`)
+  assert.Equal(t, 0, report.Failed, `Unexpected import failures.`)
+}
@@ -0,0 +1,221 @@
+package products
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "regexp"
+  "strings"
+
+  "github.com/go-sql-driver/mysql"
+  "github.com/Liquid-Labs/go-rest/rest"
+  "github.com/lib/pq"
+  "modernc.org/sqlite"
+)
+
+// MySQL server error numbers; see
+// https://dev.mysql.com/doc/refman/5.7/en/server-error-reference.html
+const (
+  mysqlErrBadNull          = 1048 // ER_BAD_NULL_ERROR
+  mysqlErrDupEntry         = 1062 // ER_DUP_ENTRY
+  mysqlErrLockWaitTimeout  = 1205 // ER_LOCK_WAIT_TIMEOUT
+  mysqlErrLockDeadlock     = 1213 // ER_LOCK_DEADLOCK
+  mysqlErrNoReferencedRow  = 1216 // ER_NO_REFERENCED_ROW
+  mysqlErrRowIsReferenced  = 1217 // ER_ROW_IS_REFERENCED
+  mysqlErrDataTruncated    = 1265 // WARN_DATA_TRUNCATED; e.g., invalid ENUM
+  mysqlErrTruncatedValue   = 1366 // ER_TRUNCATED_WRONG_VALUE_FOR_FIELD
+  mysqlErrDataTooLong      = 1406 // ER_DATA_TOO_LONG
+  mysqlErrRowIsReferenced2 = 1451 // ER_ROW_IS_REFERENCED_2
+  mysqlErrNoReferencedRow2 = 1452 // ER_NO_REFERENCED_ROW_2
+)
+
+// The column named in MySQL error messages appears as "column 'x'",
+// "Column 'x'", or "FOREIGN KEY (`x`)".
+var mysqlColumnRegexp = regexp.MustCompile("(?:[Cc]olumn '|FOREIGN KEY \\(`)([a-z_]+)")
+
+// SQLite extended result codes; see https://www.sqlite.org/rescode.html
+const (
+  sqliteBusy              = 5    // SQLITE_BUSY
+  sqliteLocked            = 6    // SQLITE_LOCKED
+  sqliteConstraintCheck   = 275  // SQLITE_CONSTRAINT_CHECK
+  sqliteConstraintFK      = 787  // SQLITE_CONSTRAINT_FOREIGNKEY
+  sqliteConstraintNotNull = 1299 // SQLITE_CONSTRAINT_NOTNULL
+  sqliteConstraintPK      = 1555 // SQLITE_CONSTRAINT_PRIMARYKEY
+  sqliteConstraintUnique  = 2067 // SQLITE_CONSTRAINT_UNIQUE
+)
+
+// The column named in SQLite constraint messages appears as
+// "failed: products.x". CHECK constraints name the condition instead, which
+// begins with the column.
+var sqliteColumnRegexp = regexp.MustCompile(`failed: (?:products\.)?([a-z_]+)`)
+
+// PostgreSQL SQLSTATE codes; see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+  pgStringTooLong        = pq.ErrorCode(`22001`) // string_data_right_truncation
+  pgInvalidText          = pq.ErrorCode(`22P02`) // invalid_text_representation; e.g., invalid ENUM
+  pgNotNull              = pq.ErrorCode(`23502`) // not_null_violation
+  pgForeignKey           = pq.ErrorCode(`23503`) // foreign_key_violation
+  pgUnique               = pq.ErrorCode(`23505`) // unique_violation
+  pgCheck                = pq.ErrorCode(`23514`) // check_violation
+  pgSerializationFailure = pq.ErrorCode(`40001`) // serialization_failure
+  pgDeadlock             = pq.ErrorCode(`40P01`) // deadlock_detected
+  pgLockNotAvailable     = pq.ErrorCode(`55P03`) // lock_not_available
+  pgQueryCanceled        = pq.ErrorCode(`57014`) // query_canceled; e.g., statement_timeout
+)
+
+// PostgreSQL names the column of a foreign key violation in the detail, as
+// "Key (x)=(...) is not present", and the type, rather than the column, of an
+// invalid ENUM value.
+var pgKeyColumnRegexp = regexp.MustCompile(`^Key \(([a-z_]+)\)`)
+var pgEnumRegexp = regexp.MustCompile(`enum ([a-z_]+)`)
+var pgEnumColumns = map[string]string{`product_ontology`: `ontology`}
+
+// retryableError indicates a transient failure, such as a deadlock, after
+// which the entire transaction may be retried.
+type retryableError struct {
+  productError
+}
+
+// IsRetryableError reports whether the failed operation may succeed if the
+// entire transaction is retried.
+func IsRetryableError(restErr rest.RestError) bool {
+  _, ok := restErr.(retryableError)
+  return ok
+}
+
+// ClassifySQLError translates a database error into the rest.RestError best
+// describing the failure. 'message' describes the failed operation and is used
+// for those errors which aren't attributable to the request data.
+//
+// * A canceled context results in a 499 and an expired one in a 504.
+// * Duplicate keys and references from other records result in a 409.
+// * Missing references (e.g., an unknown 'legalOwnerPubID'), over-long values,
+//   invalid ENUM values, and missing required values result in a
+//   ValidationError naming the Product field.
+// * Deadlocks, serialization failures, lock wait timeouts, and busy databases
+//   result in a retryable 503; see IsRetryableError.
+// * Anything else is a rest.ServerError.
+func ClassifySQLError(message string, err error) rest.RestError {
+  // The errors may be wrapped; e.g., by 'fmt.Errorf' with '%w'.
+  if errors.Is(err, context.Canceled) {
+    return canceledError(message + ` Request canceled.`, err)
+  } else if errors.Is(err, context.DeadlineExceeded) {
+    return timeoutError(message + ` Timed out.`, err)
+  }
+
+  var sqliteErr *sqlite.Error
+  if errors.As(err, &sqliteErr) {
+    return classifySQLiteError(sqliteErr, message)
+  }
+  var pqErr *pq.Error
+  if errors.As(err, &pqErr) {
+    return classifyPostgresError(pqErr, message)
+  }
+  var mysqlErr *mysql.MySQLError
+  if !errors.As(err, &mysqlErr) {
+    return rest.ServerError(message, err)
+  }
+  column := ``
+  if match := mysqlColumnRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+    column = match[1]
+  }
+
+  switch mysqlErr.Number {
+  case mysqlErrDupEntry:
+    return conflictError(message + ` Record already exists.`, err)
+  case mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2:
+    return conflictError(message + ` Record is referenced by other records.`, err)
+  case mysqlErrNoReferencedRow, mysqlErrNoReferencedRow2:
+    return fieldError(column, `references a non-existent record`, message, err)
+  case mysqlErrDataTooLong:
+    return fieldError(column, `value is too long`, message, err)
+  case mysqlErrDataTruncated, mysqlErrTruncatedValue:
+    return fieldError(column, `invalid value`, message, err)
+  case mysqlErrBadNull:
+    return fieldError(column, `required`, message, err)
+  case mysqlErrLockDeadlock, mysqlErrLockWaitTimeout:
+    return busyError(message, err)
+  default:
+    return rest.ServerError(message, err)
+  }
+}
+
+// classifySQLiteError implements ClassifySQLError for the SQLite driver.
+// SQLite does not enforce column lengths, so there is no 'too long' case.
+func classifySQLiteError(sqliteErr *sqlite.Error, message string) rest.RestError {
+  column := ``
+  if match := sqliteColumnRegexp.FindStringSubmatch(sqliteErr.Error()); match != nil {
+    column = match[1]
+  }
+
+  switch code := sqliteErr.Code(); {
+  case code == sqliteConstraintUnique || code == sqliteConstraintPK:
+    return conflictError(message + ` Record already exists.`, sqliteErr)
+  case code == sqliteConstraintFK:
+    // SQLite doesn't say which reference failed.
+    return fieldError(``, `references a non-existent record`, message, sqliteErr)
+  case code == sqliteConstraintCheck:
+    return fieldError(column, `invalid value`, message, sqliteErr)
+  case code == sqliteConstraintNotNull:
+    return fieldError(column, `required`, message, sqliteErr)
+  case code & 0xff == sqliteBusy || code & 0xff == sqliteLocked:
+    return busyError(message, sqliteErr)
+  default:
+    return rest.ServerError(message, sqliteErr)
+  }
+}
+
+// classifyPostgresError implements ClassifySQLError for the PostgreSQL driver.
+func classifyPostgresError(pqErr *pq.Error, message string) rest.RestError {
+  switch pqErr.Code {
+  case pgUnique:
+    return conflictError(message + ` Record already exists.`, pqErr)
+  case pgForeignKey:
+    if strings.Contains(pqErr.Detail, `is still referenced`) {
+      return conflictError(message + ` Record is referenced by other records.`, pqErr)
+    }
+    column := ``
+    if match := pgKeyColumnRegexp.FindStringSubmatch(pqErr.Detail); match != nil {
+      column = match[1]
+    }
+    return fieldError(column, `references a non-existent record`, message, pqErr)
+  case pgStringTooLong:
+    // PostgreSQL doesn't say which column.
+    return fieldError(``, `value is too long`, message, pqErr)
+  case pgInvalidText:
+    column := ``
+    if match := pgEnumRegexp.FindStringSubmatch(pqErr.Message); match != nil {
+      column = pgEnumColumns[match[1]]
+    }
+    return fieldError(column, `invalid value`, message, pqErr)
+  case pgCheck:
+    return fieldError(pqErr.Column, `invalid value`, message, pqErr)
+  case pgNotNull:
+    return fieldError(pqErr.Column, `required`, message, pqErr)
+  case pgSerializationFailure, pgDeadlock, pgLockNotAvailable:
+    return busyError(message, pqErr)
+  case pgQueryCanceled:
+    return timeoutError(message + ` Timed out.`, pqErr)
+  default:
+    return rest.ServerError(message, pqErr)
+  }
+}
+
+func busyError(message string, err error) rest.RestError {
+  return retryableError{productError{message + ` Database busy; try again.`, http.StatusServiceUnavailable, err}}
+}
+
+// fieldError generates a ValidationError for the Product field corresponding
+// to the column named in the database error. If the column is unknown, the
+// error is reported as an unattributed 422.
+func fieldError(column string, problem string, message string, err error) rest.RestError {
+  for _, field := range productFields {
+    if column != `` && field.column == column {
+      return ValidationError{FieldErrors{field.jsonName: {problem}}}
+    }
+  }
+
+  return rest.UnprocessableEntityError(fmt.Sprintf(`%s Invalid data; %s.`, message, problem), err)
+}
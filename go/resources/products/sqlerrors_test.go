@@ -0,0 +1,63 @@
+package products_test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/go-sql-driver/mysql"
+  "github.com/lib/pq"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestClassifySQLError(t *testing.T) {
+  tests := []struct {
+    err       error
+    code      int
+    field     string
+    retryable bool
+  }{
+    {&mysql.MySQLError{1062, "Duplicate entry '3' for key 'PRIMARY'"}, http.StatusConflict, ``, false},
+    {&mysql.MySQLError{1451, "Cannot delete or update a parent row: a foreign key constraint fails"}, http.StatusConflict, ``, false},
+    {&mysql.MySQLError{1452, "Cannot add or update a child row: a foreign key constraint fails (`catalyst`.`products`, CONSTRAINT `products_ref_users` FOREIGN KEY (`legal_owner`) REFERENCES `users` (`id`))"}, http.StatusUnprocessableEntity, `legalOwnerPubID`, false},
+    {&mysql.MySQLError{1406, "Data too long for column 'display_name' at row 1"}, http.StatusUnprocessableEntity, `displayName`, false},
+    {&mysql.MySQLError{1265, "Data truncated for column 'ontology' at row 1"}, http.StatusUnprocessableEntity, `ontology`, false},
+    {&mysql.MySQLError{1048, "Column 'summary' cannot be null"}, http.StatusUnprocessableEntity, `summary`, false},
+    {&mysql.MySQLError{1366, "Incorrect string value: '\\xF0' for column 'unknown_col' at row 1"}, http.StatusUnprocessableEntity, ``, false},
+    {&mysql.MySQLError{1213, "Deadlock found when trying to get lock; try restarting transaction"}, http.StatusServiceUnavailable, ``, true},
+    {&mysql.MySQLError{1205, "Lock wait timeout exceeded; try restarting transaction"}, http.StatusServiceUnavailable, ``, true},
+    {&mysql.MySQLError{1146, "Table 'catalyst.products' doesn't exist"}, http.StatusInternalServerError, ``, false},
+    {&pq.Error{Code: `23505`, Message: `duplicate key value violates unique constraint "entities_pub_id_unique"`}, http.StatusConflict, ``, false},
+    {&pq.Error{Code: `23503`, Detail: `Key (id)=(3) is still referenced from table "products".`}, http.StatusConflict, ``, false},
+    {&pq.Error{Code: `23503`, Detail: `Key (legal_owner)=(99) is not present in table "users".`}, http.StatusUnprocessableEntity, `legalOwnerPubID`, false},
+    {&pq.Error{Code: `22P02`, Message: `invalid input value for enum product_ontology: "BAUBLE"`}, http.StatusUnprocessableEntity, `ontology`, false},
+    {&pq.Error{Code: `23502`, Column: `support_email`}, http.StatusUnprocessableEntity, `supportEmail`, false},
+    {&pq.Error{Code: `22001`, Message: `value too long for type character varying(128)`}, http.StatusUnprocessableEntity, ``, false},
+    {&pq.Error{Code: `40P01`, Message: `deadlock detected`}, http.StatusServiceUnavailable, ``, true},
+    {&pq.Error{Code: `40001`, Message: `could not serialize access due to concurrent update`}, http.StatusServiceUnavailable, ``, true},
+    {&pq.Error{Code: `42P01`, Message: `relation "products" does not exist`}, http.StatusInternalServerError, ``, false},
+    {context.Canceled, StatusClientClosedRequest, ``, false},
+    {context.DeadlineExceeded, http.StatusGatewayTimeout, ``, false},
+    {fmt.Errorf(`query: %w`, context.Canceled), StatusClientClosedRequest, ``, false},
+    {fmt.Errorf(`query: %w`, context.DeadlineExceeded), http.StatusGatewayTimeout, ``, false},
+    {fmt.Errorf(`exec: %w`, &mysql.MySQLError{1213, "Deadlock found when trying to get lock; try restarting transaction"}), http.StatusServiceUnavailable, ``, true},
+    {errors.New(`connection refused`), http.StatusInternalServerError, ``, false},
+  }
+
+  for _, test := range tests {
+    restErr := ClassifySQLError(`Test operation.`, test.err)
+    require.NotNil(t, restErr, `Unexpected nil error for '%s'.`, test.err)
+    assert.Equal(t, test.code, restErr.Code(), `Unexpected code for '%s'.`, test.err)
+    assert.Equal(t, test.retryable, IsRetryableError(restErr), `Unexpected retryable status for '%s'.`, test.err)
+    if test.field != `` {
+      validationErr, ok := restErr.(ValidationError)
+      require.True(t, ok, `Expected ValidationError for '%s'.`, test.err)
+      assert.Contains(t, validationErr.Fields, test.field)
+      assert.Len(t, validationErr.Fields, 1)
+    }
+  }
+}
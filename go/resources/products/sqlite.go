@@ -0,0 +1,50 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+  "net/url"
+
+  _ "modernc.org/sqlite" // registers the pure-Go 'sqlite' driver
+)
+
+// SQLiteDialect supports a self-contained SQLite database for local
+// development and testing. The migrations create minimal 'entities' and
+// 'users' tables in place of those from catalyst-core-api. See OpenSQLite.
+var SQLiteDialect = &Dialect{
+  Name: `sqlite`,
+  likeOperator: `LIKE`, // case-insensitive for ASCII
+  lockClause: ``, // write transactions lock the database; see OpenSQLite
+  // Ensure each change produces a new version, even within the same second.
+  touchExpr: `MAX(last_updated + 1, CAST(strftime('%s', 'now') AS INTEGER))`,
+  epochFormat: `CAST(strftime('%%s', %s) AS INTEGER)`,
+  fullText: sqliteFullText,
+  adoptionProbes: completeSchemaProbes,
+  createEntityInTxn: insertEntityInTxn,
+  // The immediate transactions in which each migration is applied serialize
+  // the migrations.
+  lockMigrations: func(*sql.Conn, context.Context) (func(), error) { return func() {}, nil },
+}
+
+// OpenSQLite opens, creating if necessary, the SQLite database file at 'path'
+// and sets it up for use with SQLiteDialect. Foreign keys are enforced and
+// transactions begin immediately, so concurrent writers wait on each other
+// rather than fail when upgrading their locks. The path must name a file;
+// ':memory:' databases are not shared between connections.
+//
+// If setup fails, the database is returned along with the error; see
+// SetupDBWithDialect.
+func OpenSQLite(path string) (*sql.DB, error) {
+  params := url.Values{}
+  params.Add(`_pragma`, `foreign_keys(1)`)
+  params.Add(`_pragma`, `busy_timeout(5000)`)
+  params.Add(`_pragma`, `journal_mode(WAL)`)
+  params.Set(`_txlock`, `immediate`)
+  db, err := sql.Open(`sqlite`, `file:` + path + `?` + params.Encode())
+  if err != nil {
+    return nil, fmt.Errorf("sqlite: open '%s': %v", path, err)
+  }
+
+  return db, SetupDBWithDialect(db, SQLiteDialect)
+}
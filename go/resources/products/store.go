@@ -0,0 +1,79 @@
+package products
+
+import (
+  "context"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// ProductStore abstracts the persistence of Products. Implementations must be
+// safe for concurrent use and must behave as the SQL implementation does:
+// Products are validated before saving, updates require a matching
+// 'LastUpdated' (see UpdateProduct), and deletion archives the Product.
+type ProductStore interface {
+  // Get retrieves a Product by public ID. Archived Products are treated as
+  // not found unless 'includeArchived' is true.
+  Get(pubId string, includeArchived bool, ctx context.Context) (*Product, rest.RestError)
+  // GetByID retrieves a Product by internal ID, whether or not it is archived.
+  GetByID(id int64, ctx context.Context) (*Product, rest.RestError)
+  Create(p *Product, ctx context.Context) (*Product, rest.RestError)
+  Update(p *Product, ctx context.Context) (*Product, rest.RestError)
+  // Delete archives the Product identified by the public ID.
+  Delete(pubId string, ctx context.Context) (*Product, rest.RestError)
+  // List retrieves the page of Products described by the search parameters
+  // and filters, and sets the total counts on 'searchParams.PageInfo'. See
+  // ListProducts.
+  List(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*Product, rest.RestError)
+  // ListPage retrieves the page of Products described by the cursor, the
+  // search parameters, and the filters. See ListProductsPage.
+  ListPage(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, cursor string, ctx context.Context) (*ProductPage, rest.RestError)
+  // Facets counts the Products described by the search parameters and
+  // filters by facet. See ListProductFacets.
+  Facets(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) (*ProductFacets, rest.RestError)
+  // Suggest finds the Products, passing the filters, with display names
+  // resembling the query. See SuggestProducts.
+  Suggest(query string, limit int, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*ProductSuggestion, rest.RestError)
+}
+
+// SQLStore is the ProductStore backed by the package functions and the
+// statements prepared by SetupDB or OpenSQLite.
+type SQLStore struct{}
+
+func (SQLStore) Get(pubId string, includeArchived bool, ctx context.Context) (*Product, rest.RestError) {
+  if includeArchived {
+    return GetProductIncludeArchived(pubId, ctx)
+  }
+  return GetProduct(pubId, ctx)
+}
+
+func (SQLStore) GetByID(id int64, ctx context.Context) (*Product, rest.RestError) {
+  return GetProductByID(id, ctx)
+}
+
+func (SQLStore) Create(p *Product, ctx context.Context) (*Product, rest.RestError) {
+  return CreateProduct(p, ctx)
+}
+
+func (SQLStore) Update(p *Product, ctx context.Context) (*Product, rest.RestError) {
+  return UpdateProduct(p, ctx)
+}
+
+func (SQLStore) Delete(pubId string, ctx context.Context) (*Product, rest.RestError) {
+  return DeleteProduct(pubId, ctx)
+}
+
+func (SQLStore) List(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*Product, rest.RestError) {
+  return ListProducts(searchParams, includeArchived, filters, ctx)
+}
+
+func (SQLStore) ListPage(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, cursor string, ctx context.Context) (*ProductPage, rest.RestError) {
+  return ListProductsPage(searchParams, includeArchived, filters, cursor, ctx)
+}
+
+func (SQLStore) Facets(searchParams *rest.SearchParams, includeArchived bool, filters *ProductFilters, ctx context.Context) (*ProductFacets, rest.RestError) {
+  return ListProductFacets(searchParams, includeArchived, filters, ctx)
+}
+
+func (SQLStore) Suggest(query string, limit int, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*ProductSuggestion, rest.RestError) {
+  return SuggestProducts(query, limit, includeArchived, filters, ctx)
+}
@@ -0,0 +1,271 @@
+package products
+
+import (
+  "context"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode/utf8"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// ProductSuggestion is a Product whose display name resembles a query; see
+// SuggestProducts. The score runs from 0 to 1, where 1 is an exact match.
+type ProductSuggestion struct {
+  PubID       string  `json:"pubId"`
+  DisplayName string  `json:"displayName"`
+  Score       float64 `json:"score"`
+}
+
+// minNameSimilarity is the least similarity, per nameSimilarity, for which a
+// misspelled name is suggested.
+const minNameSimilarity = 0.6
+
+// maxSuggestQueryLength bounds, in runes, the query compared against each
+// name.
+const maxSuggestQueryLength = 100
+
+// suggestPrefixRunes is the length of the query prefix whose letter pairs
+// preselect the candidate names; see candidateBits.
+const suggestPrefixRunes = 8
+
+// maxSuggestCandidates bounds the number of names scored per query.
+const maxSuggestCandidates = 500
+
+// normalizeName lowercases the name and reduces it to its words, separated by
+// single spaces.
+func normalizeName(name string) string {
+  return strings.Join(wordRegexp.FindAllString(strings.ToLower(name), -1), ` `)
+}
+
+// nameSimilarity scores how well the normalized query matches the normalized
+// name, from 0 to 1. Completions, where the query is a prefix of the name or
+// of a word of the name, score 0.8 or more, favoring the name start and the
+// closest lengths. Otherwise, the query may be misspelled, and the score is
+// up to 0.8 times the best of:
+//
+//   - the trigram similarity, which tolerates words out of order;
+//   - the edit similarity to the name or any word of the name; and
+//   - the edit similarity to the name prefix of the query length, so that
+//     partial queries may also be misspelled.
+//
+// Misspellings with a similarity below minNameSimilarity score 0.
+func nameSimilarity(query string, name string) float64 {
+  if query == `` || name == `` {
+    return 0
+  } else if query == name {
+    return 1
+  }
+  queryLen := float64(len(query))
+  if strings.HasPrefix(name, query) {
+    return 0.9 + 0.09 * queryLen / float64(len(name))
+  }
+  words := strings.Fields(name)
+  for _, word := range words {
+    if strings.HasPrefix(word, query) {
+      return 0.8 + 0.09 * queryLen / float64(len(word))
+    }
+  }
+
+  queryRunes := []rune(query)
+  best := trigramSimilarity(query, name)
+  for _, candidate := range append([]string{name}, words...) {
+    if similarity := editSimilarity(queryRunes, []rune(candidate)); similarity > best {
+      best = similarity
+    }
+  }
+  if nameRunes := []rune(name); len(queryRunes) >= 3 && len(nameRunes) > len(queryRunes) {
+    if similarity := editSimilarity(queryRunes, nameRunes[:len(queryRunes)]); similarity > best {
+      best = similarity
+    }
+  }
+  if best < minNameSimilarity {
+    return 0
+  }
+  return 0.8 * best
+}
+
+// trigrams gathers the distinct trigrams of the words of the normalized text.
+// As with the Postgres 'pg_trgm' extension, each word is padded with two
+// leading spaces and one trailing space, so word starts count for more.
+func trigrams(text string) map[string]bool {
+  grams := make(map[string]bool)
+  for _, word := range strings.Fields(text) {
+    padded := []rune(`  ` + word + ` `)
+    for i := 0; i + 3 <= len(padded); i++ {
+      grams[string(padded[i:i + 3])] = true
+    }
+  }
+  return grams
+}
+
+// trigramSimilarity is the Dice coefficient of the trigrams of 'a' and 'b'.
+func trigramSimilarity(a string, b string) float64 {
+  aGrams, bGrams := trigrams(a), trigrams(b)
+  if len(aGrams) + len(bGrams) == 0 {
+    return 0
+  }
+  shared := 0
+  for gram := range aGrams {
+    if bGrams[gram] {
+      shared += 1
+    }
+  }
+  return 2 * float64(shared) / float64(len(aGrams) + len(bGrams))
+}
+
+// editSimilarity is 1 less the edit distance between 'a' and 'b' relative to
+// the longer length. The distance counts insertions, deletions, substitutions,
+// and transpositions of adjacent runes (the 'optimal string alignment'
+// distance).
+func editSimilarity(a []rune, b []rune) float64 {
+  longer := len(a)
+  if len(b) > longer {
+    longer = len(b)
+  }
+  if longer == 0 {
+    return 1
+  }
+
+  // Only three rows of the distance matrix are needed.
+  prev2, prev, row := make([]int, len(b) + 1), make([]int, len(b) + 1), make([]int, len(b) + 1)
+  for j := range prev {
+    prev[j] = j
+  }
+  for i := 1; i <= len(a); i++ {
+    row[0] = i
+    for j := 1; j <= len(b); j++ {
+      cost := 1
+      if a[i - 1] == b[j - 1] {
+        cost = 0
+      }
+      row[j] = minInt(minInt(prev[j] + 1, row[j - 1] + 1), prev[j - 1] + cost)
+      if i > 1 && j > 1 && a[i - 1] == b[j - 2] && a[i - 2] == b[j - 1] {
+        row[j] = minInt(row[j], prev2[j - 2] + 1)
+      }
+    }
+    prev2, prev, row = prev, row, prev2
+  }
+  return 1 - float64(prev[len(b)]) / float64(longer)
+}
+
+func minInt(a int, b int) int {
+  if a < b {
+    return a
+  }
+  return b
+}
+
+// nameMatcher gathers the best suggestions for a query from the candidate
+// names.
+type nameMatcher struct {
+  query       string
+  suggestions []*ProductSuggestion
+}
+
+func newNameMatcher(query string) *nameMatcher {
+  query = normalizeName(query)
+  if utf8.RuneCountInString(query) > maxSuggestQueryLength {
+    query = string([]rune(query)[:maxSuggestQueryLength])
+  }
+  return &nameMatcher{query: query, suggestions: make([]*ProductSuggestion, 0)}
+}
+
+func (m *nameMatcher) add(pubID string, displayName string) {
+  if score := nameSimilarity(m.query, normalizeName(displayName)); score > 0 {
+    m.suggestions = append(m.suggestions, &ProductSuggestion{PubID: pubID, DisplayName: displayName, Score: score})
+  }
+}
+
+// best returns up to 'limit' suggestions, by descending score and then by
+// name.
+func (m *nameMatcher) best(limit int) []*ProductSuggestion {
+  sort.Slice(m.suggestions, func(i, j int) bool {
+    a, b := m.suggestions[i], m.suggestions[j]
+    if a.Score != b.Score {
+      return a.Score > b.Score
+    }
+    return a.DisplayName < b.DisplayName || (a.DisplayName == b.DisplayName && a.PubID < b.PubID)
+  })
+  if len(m.suggestions) > limit {
+    return m.suggestions[:limit]
+  }
+  return m.suggestions
+}
+
+// candidateBits builds the condition preselecting the names which may
+// resemble the normalized query, so that the whole catalog is not scored for
+// each query: those with a word starting as a word of the query does, or
+// sharing a pair of letters with the query prefix. Completions always qualify,
+// and misspellings seldom score without either.
+func candidateBits(query string, like string) (string, []interface{}) {
+  patterns := make([]interface{}, 0)
+  seen := make(map[string]bool)
+  add := func(pattern string) {
+    if !seen[pattern] {
+      seen[pattern] = true
+      patterns = append(patterns, pattern)
+    }
+  }
+  for _, word := range strings.Fields(query) {
+    initial := string([]rune(word)[0])
+    add(initial + `%`)
+    add(`% ` + initial + `%`)
+  }
+  prefix := []rune(query)
+  if len(prefix) > suggestPrefixRunes {
+    prefix = prefix[:suggestPrefixRunes]
+  }
+  for i := 0; i + 2 <= len(prefix); i++ {
+    if pair := string(prefix[i:i + 2]); !strings.Contains(pair, ` `) {
+      add(`%` + pair + `%`)
+    }
+  }
+
+  likeBits := make([]string, len(patterns))
+  for i := range patterns {
+    likeBits[i] = `p.display_name ` + like + ` ?`
+  }
+  return `AND (` + strings.Join(likeBits, ` OR `) + `) `, patterns
+}
+
+// SuggestProducts finds up to 'limit' Products with display names resembling
+// the query, best first, for autocompletion and to correct misspelled
+// searches; see nameSimilarity. Only Products passing the filters are
+// considered. The names are preselected in SQL, up to maxSuggestCandidates,
+// by candidateBits; the matching is then done in process, so no index or
+// database extension is required.
+func SuggestProducts(query string, limit int, includeArchived bool, filters *ProductFilters, ctx context.Context) ([]*ProductSuggestion, rest.RestError) {
+  bits, restErr := buildSearchBits(&rest.SearchParams{}, includeArchived, filters)
+  if restErr != nil {
+    return nil, restErr
+  }
+  matcher := newNameMatcher(query)
+  if matcher.query == `` {
+    return matcher.best(limit), nil
+  }
+  candidateBit, candidateParams := candidateBits(matcher.query, sqlDialect().likeOperator)
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
+
+  candidateQuery := `SELECT e.pub_id, p.display_name ` + CommonProductsFrom + bits.where + candidateBit + `ORDER BY p.display_name LIMIT ` + strconv.Itoa(maxSuggestCandidates)
+  rows, err := productsDB().QueryContext(ctx, sqlDialect().Rebind(candidateQuery), append(bits.params, candidateParams...)...)
+  if err != nil {
+    return nil, ClassifySQLError("Error retrieving product names.", err)
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    var pubID, displayName string
+    if err := rows.Scan(&pubID, &displayName); err != nil {
+      return nil, rest.ServerError("Problem getting product names.", err)
+    }
+    matcher.add(pubID, displayName)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, ClassifySQLError("Error retrieving product names.", err)
+  }
+
+  return matcher.best(limit), nil
+}
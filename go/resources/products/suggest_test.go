@@ -0,0 +1,55 @@
+package products_test
+
+import (
+  "context"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func suggestionNames(suggestions []*ProductSuggestion) []string {
+  names := make([]string, 0, len(suggestions))
+  for _, suggestion := range suggestions {
+    names = append(names, suggestion.DisplayName)
+  }
+  return names
+}
+
+func TestMemoryStoreSuggest(t *testing.T) {
+  store := NewMemoryStore()
+  ctx := context.Background()
+  for _, name := range []string{`Gizmo`, `Gizmo Pro`, `Wall Hanging`, `Widget`, `Archived Widget`} {
+    p := widgetProduct.Clone()
+    p.SetDisplayName(name)
+    if name == `Gizmo Pro` {
+      p.SetOntology(`SOFTWARE SERVICE`)
+    }
+    p, restErr := store.Create(p, ctx)
+    require.NoError(t, restErr, `Unexpected error creating Product.`)
+    if name == `Archived Widget` {
+      _, restErr = store.Delete(p.PubId.String, ctx)
+      require.NoError(t, restErr, `Unexpected error deleting Product.`)
+    }
+  }
+
+  suggest := func(query string, limit int, filters *ProductFilters) []string {
+    suggestions, restErr := store.Suggest(query, limit, false, filters, ctx)
+    require.NoError(t, restErr, `Unexpected error suggesting Products.`)
+    return suggestionNames(suggestions)
+  }
+  // Completions come before misspellings; the exact match comes first.
+  assert.Equal(t, []string{`Gizmo`, `Gizmo Pro`}, suggest(`gizmo`, 10, nil), `Unexpected completions.`)
+  assert.Equal(t, []string{`Gizmo`}, suggest(`gizmo`, 1, nil), `Unexpected limited completions.`)
+  assert.Equal(t, []string{`Wall Hanging`}, suggest(`hang`, 10, nil), `Unexpected word completion.`)
+  assert.Equal(t, []string{`Widget`}, suggest(`Wdiget`, 10, nil), `Unexpected transposition suggestion.`)
+  assert.Equal(t, []string{`Wall Hanging`}, suggest(`haning wall`, 10, nil), `Unexpected reordered suggestion.`)
+  assert.Equal(t, []string{`Gizmo`, `Gizmo Pro`}, suggest(`gizm0`, 10, nil), `Unexpected partial misspelling.`)
+  assert.Equal(t, []string{`Gizmo Pro`}, suggest(`gizmo`, 10, &ProductFilters{Ontologies: []string{`SOFTWARE SERVICE`}}), `Unexpected filtered suggestions.`)
+  assert.Empty(t, suggest(`sprocket`, 10, nil), `Unexpected suggestions for unrelated name.`)
+
+  suggestions, restErr := store.Suggest(`archived widgt`, 10, true, nil, ctx)
+  require.NoError(t, restErr, `Unexpected error suggesting Products.`)
+  assert.Equal(t, `Archived Widget`, suggestionNames(suggestions)[0], `Expected archived Product.`)
+}
@@ -0,0 +1,97 @@
+package products
+
+import (
+  "context"
+  "database/sql"
+  "math/rand"
+  "time"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// QueryTimeout limits the time allowed for each product operation, such as a
+// retrieval or an update including its retries. Zero disables the limit, in
+// which case operations are bound only by the caller's context.
+var QueryTimeout = 30 * time.Second
+
+// operationContext derives a context limited by QueryTimeout. The returned
+// cancel function must be called once the operation completes.
+func operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+  if QueryTimeout <= 0 {
+    return context.WithCancel(ctx)
+  }
+  return context.WithTimeout(ctx, QueryTimeout)
+}
+
+// TxnMaxRetries is the number of times WithTxn retries a transaction which
+// failed with a retryable error, such as a deadlock.
+var TxnMaxRetries = 3
+
+// TxnRetryBackoff is the base delay before WithTxn retries a transaction. The
+// delay doubles with each attempt and is jittered to avoid lock-step retries.
+var TxnRetryBackoff = 25 * time.Millisecond
+
+// WithTxn runs 'f' within a new transaction. If 'f' returns nil, the
+// transaction is committed and any commit error is returned. Otherwise, or if
+// 'f' panics, the transaction is rolled back. Either way, the transaction is
+// finished exactly once, so 'f' and the '*InTxn' functions it calls must not
+// commit or roll back the transaction themselves.
+//
+// If the transaction fails with a retryable error (see IsRetryableError), it
+// is retried from the start, with backoff, up to TxnMaxRetries times. 'f' must
+// therefore be safe to run more than once.
+//
+// The transaction, including any retries, is limited by QueryTimeout. If 'ctx'
+// is canceled or the time runs out, the transaction is rolled back and the
+// cancellation or timeout is reported in place of any error from 'f'.
+func WithTxn(ctx context.Context, f func(*sql.Tx) rest.RestError) rest.RestError {
+  ctx, cancel := operationContext(ctx)
+  defer cancel()
+
+  backoff := TxnRetryBackoff
+  for attempt := 0; ; attempt += 1 {
+    restErr := runTxn(ctx, f)
+    if restErr == nil || !IsRetryableError(restErr) || attempt >= TxnMaxRetries {
+      return restErr
+    }
+
+    delay := backoff + time.Duration(rand.Int63n(int64(backoff)))
+    select {
+    case <-ctx.Done():
+      return restErr
+    case <-time.After(delay):
+    }
+    backoff *= 2
+  }
+}
+
+func runTxn(ctx context.Context, f func(*sql.Tx) rest.RestError) rest.RestError {
+  txn, err := productsDB().BeginTx(ctx, nil)
+  if err != nil {
+    return ClassifySQLError("Could not begin transaction.", err)
+  }
+  finished := false
+  defer func() {
+    if !finished {
+      txn.Rollback()
+    }
+  }()
+
+  if restErr := f(txn); restErr != nil {
+    if ctxErr := ctx.Err(); ctxErr != nil {
+      // the txn is rolled back by the driver; 'f' sees only the fallout
+      return ClassifySQLError("Transaction aborted.", ctxErr)
+    }
+    return restErr
+  }
+
+  finished = true
+  if err := txn.Commit(); err != nil {
+    if ctxErr := ctx.Err(); ctxErr != nil {
+      err = ctxErr
+    }
+    return ClassifySQLError("Could not commit transaction.", err)
+  }
+
+  return nil
+}
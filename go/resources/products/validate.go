@@ -0,0 +1,149 @@
+package products
+
+import (
+  "fmt"
+  "net/http"
+  "net/mail"
+  "net/url"
+  "regexp"
+  "sort"
+  "strings"
+  "unicode/utf8"
+
+  "github.com/Liquid-Labs/go-rest/rest"
+)
+
+// FieldErrors maps Product JSON field names to the problems found with the
+// field value.
+type FieldErrors map[string][]string
+
+func (fe FieldErrors) add(field string, message string) {
+  fe[field] = append(fe[field], message)
+}
+
+// ValidationError is a rest.RestError reporting invalid Product data by field.
+type ValidationError struct {
+  Fields FieldErrors
+}
+
+func (e ValidationError) Error() string {
+  fields := make([]string, 0, len(e.Fields))
+  for field := range e.Fields {
+    fields = append(fields, field)
+  }
+  sort.Strings(fields)
+
+  problems := make([]string, 0, len(fields))
+  for _, field := range fields {
+    problems = append(problems, field + `: ` + strings.Join(e.Fields[field], `; `))
+  }
+  return `Invalid product data; ` + strings.Join(problems, `, `) + `.`
+}
+
+func (e ValidationError) Code() int {
+  return http.StatusUnprocessableEntity
+}
+
+func (e ValidationError) Cause() error {
+  return nil
+}
+
+// legalOwnerNotFoundError reports a 'legalOwnerPubID' which does not identify
+// a user.
+func legalOwnerNotFoundError(legalOwnerPubID string) rest.RestError {
+  return ValidationError{FieldErrors{`legalOwnerPubID`: {fmt.Sprintf(`legal owner '%s' not found`, legalOwnerPubID)}}}
+}
+
+// productConstraints mirror the 'products' schema: NOT NULL columns are
+// required and the maximum lengths match the VARCHAR sizes. The 'supportPhone'
+// length counts only digits, as the phone is stored without punctuation.
+var productConstraints = map[string]struct {
+  required  bool
+  maxLength int
+}{
+  `legalOwnerPubID`: {true, 36},
+  `displayName`: {true, 128},
+  `summary`: {true, 512},
+  `supportEmail`: {true, 255},
+  `supportPhone`: {false, 12},
+  `homepage`: {false, 255},
+  `logoURL`: {false, 255},
+  `repoURL`: {false, 255},
+  `issuesURL`: {false, 255},
+  `ontology`: {false, 0},
+}
+
+// phoneFormat matches the phone numbers accepted: digits with the punctuation
+// the 'products_phone_format' triggers strip.
+var phoneFormat = regexp.MustCompile(`^[0-9 ()+.-]*[0-9][0-9 ()+.-]*$`)
+
+var urlFields = map[string]bool{`homepage`: true, `logoURL`: true, `repoURL`: true, `issuesURL`: true}
+
+// Validate checks the writable Product fields and returns a ValidationError
+// describing any problems, or nil if the Product is valid.
+func (p *Product) Validate() *ValidationError {
+  fields := make([]string, len(productFields))
+  for i, field := range productFields {
+    fields[i] = field.jsonName
+  }
+  return p.ValidateFields(fields)
+}
+
+// ValidateFields is like Validate, but checks only the named fields. This is
+// used to validate partial updates.
+func (p *Product) ValidateFields(fields []string) *ValidationError {
+  errs := make(FieldErrors)
+  for _, name := range fields {
+    field, ok := findProductField(name)
+    if !ok {
+      errs.add(name, `unknown or read-only field`)
+      continue
+    }
+    value := field.value(p)
+    constraint := productConstraints[name]
+    if !value.Valid || strings.TrimSpace(value.String) == `` {
+      if constraint.required {
+        errs.add(name, `required`)
+      }
+      continue
+    }
+
+    if constraint.maxLength > 0 && name != `supportPhone` && utf8.RuneCountInString(value.String) > constraint.maxLength {
+      errs.add(name, fmt.Sprintf(`must be at most %d characters`, constraint.maxLength))
+    }
+    switch {
+    case name == `supportPhone`:
+      if !phoneFormat.MatchString(value.String) {
+        errs.add(name, `must be a phone number of digits, spaces and '()+.-'`)
+      } else if len(nonNumeric.ReplaceAllString(value.String, ``)) > constraint.maxLength {
+        errs.add(name, fmt.Sprintf(`must have at most %d digits`, constraint.maxLength))
+      }
+    case name == `supportEmail`:
+      if addr, err := mail.ParseAddress(value.String); err != nil || addr.Address != value.String {
+        errs.add(name, `must be a valid email address`)
+      }
+    case name == `ontology`:
+      if !isOntology(value.String) {
+        errs.add(name, fmt.Sprintf(`must be one of '%s'`, strings.Join(ProductOntologies, `', '`)))
+      }
+    case urlFields[name]:
+      if u, err := url.Parse(value.String); err != nil || (u.Scheme != `http` && u.Scheme != `https`) || u.Host == `` {
+        errs.add(name, `must be a valid http(s) URL`)
+      }
+    }
+  }
+
+  if len(errs) == 0 {
+    return nil
+  }
+  return &ValidationError{errs}
+}
+
+func isOntology(val string) bool {
+  for _, ontology := range ProductOntologies {
+    if val == ontology {
+      return true
+    }
+  }
+  return false
+}
@@ -0,0 +1,63 @@
+package products_test
+
+import (
+  "net/http"
+  "strings"
+  "testing"
+
+  . "github.com/Liquid-Labs/catalyst-products-api/go/resources/products"
+  "github.com/Liquid-Labs/go-nullable-mysql/nulls"
+  "github.com/stretchr/testify/assert"
+  "github.com/stretchr/testify/require"
+)
+
+func TestProductValidate(t *testing.T) {
+  assert.Nil(t, widgetProduct.Validate(), `Unexpected validation error for valid Product.`)
+
+  invalid := widgetProduct.Clone()
+  invalid.DisplayName = nulls.NewNullString()
+  invalid.SetSummary(`  `)
+  invalid.SetSupportEmail(`not an email`)
+  invalid.SetSupportPhone(`+44 555-555-55555`)
+  invalid.SetHomepage(`ftp://foo.com`)
+  invalid.SetLogoURL(`/relative/logo.svg`)
+  invalid.SetRepoURL(`https://foo.com/` + strings.Repeat(`a`, 255))
+  invalid.SetOntology(`INTANGIBLE GOOD`)
+
+  validationErr := invalid.Validate()
+  require.NotNil(t, validationErr, `Expected validation error.`)
+  assert.Equal(t, http.StatusUnprocessableEntity, validationErr.Code(), `Unexpected error code.`)
+  assert.Equal(t, FieldErrors{
+    `displayName`: {`required`},
+    `summary`: {`required`},
+    `supportEmail`: {`must be a valid email address`},
+    `supportPhone`: {`must have at most 12 digits`},
+    `homepage`: {`must be a valid http(s) URL`},
+    `logoURL`: {`must be a valid http(s) URL`},
+    `repoURL`: {`must be at most 255 characters`},
+    `ontology`: {`must be one of 'TANGIBLE GOOD', 'DIGITAL GOOD', 'SOFTWARE SERVICE', 'CONSULTING SERVICE', 'PHYSICAL SERVICE'`},
+  }, validationErr.Fields, `Unexpected field errors.`)
+}
+
+func TestProductValidateFields(t *testing.T) {
+  partial := &Product{}
+  partial.SetSupportPhone(`555-555-0000`)
+  assert.Nil(t, partial.ValidateFields([]string{`supportPhone`}), `Unexpected validation error for valid field.`)
+  validationErr := partial.ValidateFields([]string{`supportPhone`, `displayName`})
+  require.NotNil(t, validationErr, `Expected validation error.`)
+  assert.Equal(t, FieldErrors{`displayName`: {`required`}}, validationErr.Fields, `Unexpected field errors.`)
+}
+
+func TestProductValidatePhone(t *testing.T) {
+  product := widgetProduct.Clone()
+  for _, phone := range []string{`(555) 555-0001`, `+1 555 555 0001`, `555.555.0001`} {
+    product.SetSupportPhone(phone)
+    assert.Nil(t, product.ValidateFields([]string{`supportPhone`}), `Unexpected validation error for phone '%s'.`, phone)
+  }
+  for _, phone := range []string{`555-CALL-NOW`, `555-555-0001 x12`, `()`} {
+    product.SetSupportPhone(phone)
+    validationErr := product.ValidateFields([]string{`supportPhone`})
+    require.NotNil(t, validationErr, `Expected validation error for phone '%s'.`, phone)
+    assert.Equal(t, FieldErrors{`supportPhone`: {`must be a phone number of digits, spaces and '()+.-'`}}, validationErr.Fields, `Unexpected field errors.`)
+  }
+}